@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Action interface {
@@ -24,16 +26,33 @@ type ActionWithID interface {
 	Action
 }
 
+type ActionWithScope interface {
+	Scope(user usr.User) []db.SQLWhere
+	Action
+}
+
+// conditional GET (ETag, Last-Modified)
+type ActionWithCache interface {
+	Cached() bool
+	Action
+}
+
+// own middleware inside the router and controller middleware
+type ActionWithMiddleware interface {
+	Middleware() []Middleware
+	Action
+}
+
 func NewActionPagination(roles ...usr.Role) Action {
-	return NewAction(MethodGet|MethodHead, "", actionPagination, roles...)
+	return NewAction(MethodGet|MethodHead|WithCache, "", actionPagination, roles...)
 }
 
 func NewActionList(roles ...usr.Role) Action {
-	return NewAction(MethodGet|MethodHead, "", actionList, roles...)
+	return NewAction(MethodGet|MethodHead|WithCache, "", actionList, roles...)
 }
 
 func NewActionView(roles ...usr.Role) Action {
-	return NewAction(MethodGet|MethodHead|WithID, "", actionView, roles...)
+	return NewAction(MethodGet|MethodHead|WithID|WithCache, "", actionView, roles...)
 }
 
 func NewActionCreate(roles ...usr.Role) Action {
@@ -57,11 +76,25 @@ func NewAction(options uint32, path string, handler func(*Request) (int, map[str
 	return &result
 }
 
+// action wrapped by the middleware, options of the standard actions are kept
+func NewActionWithMiddleware(value Action, middleware ...Middleware) Action {
+	if a, ok := value.(*action); ok && a != nil {
+		result := *a
+		result.middleware = append(append(make([]Middleware, 0, len(a.middleware)+len(middleware)), a.middleware...), middleware...)
+		return &result
+	}
+	result := actionMiddleware{}
+	result.Action = getActionOrigin(value)
+	result.middleware = append(append(make([]Middleware, 0), getActionMiddleware(value)...), middleware...)
+	return &result
+}
+
 type action struct {
-	path    string
-	options uint32
-	roles   []usr.Role
-	handler func(*Request) (int, map[string]string, interface{}, error)
+	path       string
+	options    uint32
+	roles      []usr.Role
+	handler    func(*Request) (int, map[string]string, interface{}, error)
+	middleware []Middleware
 }
 
 func (this *action) Path() string {
@@ -104,6 +137,14 @@ func (this *action) WithID() bool {
 	return this.options&WithID == WithID
 }
 
+func (this *action) Cached() bool {
+	return this.options&WithCache == WithCache
+}
+
+func (this *action) Middleware() []Middleware {
+	return this.middleware
+}
+
 func (this *action) Roles() usr.Roles {
 	if this.roles == nil {
 		return make([]usr.Role, 0)
@@ -129,11 +170,12 @@ func actionPagination(r *Request) (int, map[string]string, interface{}, error) {
 		table := db.NewSQLTable(r.Model.Table())
 		for _, field := range f {
 			parsers[field.Name()] = field.Parser
-			fields = append(fields, db.NewSQLField(r.DB.Escape(field.Name()), nil))
+			fields = append(fields, getFieldSelect(r.DB, field))
 		}
-		where, groupBy, orderBy, having, _, _ := db.SQLParserEx(r.Request.Request, parsers, r.DB.Escape)
+		where, groupBy, orderBy, having, _, _ := db.SQLParserEx(r.Request.Request, parsers, r.column)
+		where = getScopeWhere(r, where)
 		if r.URL.ID.Value != nil {
-			where = append(where, db.NewSQLWhere(r.DB.Escape(r.URL.ID.Name), string(r.URL.ID.Value)))
+			where = append(where, db.NewSQLWhere(r.column(r.URL.ID.Name), string(r.URL.ID.Value)))
 		}
 		// page options
 		var pageNumber int64 = 0
@@ -166,11 +208,13 @@ func actionPagination(r *Request) (int, map[string]string, interface{}, error) {
 		if body, err := r.DB.Select(table, fields, where, groupBy, having, orderBy, db.NewSQLLimit(pageSize), db.NewSQLOffset(pageNumber*pageSize)); err != nil {
 			return http.StatusInternalServerError, nil, nil, err
 		} else if body != nil {
+			// by the raw values, formatters change the time values in place
+			head := getLastModified(r, body)
 			d := struct {
 				Data interface{}            `json:"data"`
 				Meta map[string]interface{} `json:"meta"`
 			}{
-				Data: r.expand(r.Model, body),
+				Data: r.format(r.Model, r.expand(r.Model, body)),
 				Meta: map[string]interface{}{
 					"total_entries": totalRows,
 					"current_page":  pageNumber + 1,
@@ -178,7 +222,7 @@ func actionPagination(r *Request) (int, map[string]string, interface{}, error) {
 					"per_page":      pageSize,
 				},
 			}
-			return http.StatusOK, nil, d, nil
+			return http.StatusOK, head, d, nil
 		}
 	}
 	return http.StatusInternalServerError, nil, nil, fmt.Errorf("unknown error")
@@ -194,16 +238,18 @@ func actionList(r *Request) (int, map[string]string, interface{}, error) {
 		table := db.NewSQLTable(r.Model.Table())
 		for _, field := range f {
 			parsers[field.Name()] = field.Parser
-			fields = append(fields, db.NewSQLField(r.DB.Escape(field.Name()), nil))
+			fields = append(fields, getFieldSelect(r.DB, field))
 		}
-		where, groupBy, orderBy, having, limit, offset := db.SQLParserEx(r.Request.Request, parsers, r.DB.Escape)
+		where, groupBy, orderBy, having, limit, offset := db.SQLParserEx(r.Request.Request, parsers, r.column)
+		where = getScopeWhere(r, where)
 		if r.URL.ID.Value != nil {
-			where = append(where, db.NewSQLWhere(r.DB.Escape(r.URL.ID.Name), string(r.URL.ID.Value)))
+			where = append(where, db.NewSQLWhere(r.column(r.URL.ID.Name), string(r.URL.ID.Value)))
 		}
 		if body, err := r.DB.Select(table, fields, where, groupBy, having, orderBy, limit, offset); err != nil {
 			return http.StatusInternalServerError, nil, nil, err
 		} else if body != nil {
-			return http.StatusOK, nil, r.expand(r.Model, body), nil
+			head := getLastModified(r, body)
+			return http.StatusOK, head, r.format(r.Model, r.expand(r.Model, body)), nil
 		}
 	}
 	return http.StatusInternalServerError, nil, nil, fmt.Errorf("unknown error")
@@ -218,15 +264,18 @@ func actionView(r *Request) (int, map[string]string, interface{}, error) {
 	if f := getModelFields(r.Model, r.User.Role(), usr.ALEVEL_READ); f != nil && len(f) > 0 {
 		fields := make([]db.SQLField, 0)
 		table := db.NewSQLTable(r.Model.Table())
-		where := []db.SQLWhere{db.NewSQLWhere(r.DB.Escape(r.URL.ID.Name), string(r.URL.ID.Value))}
+		where := getScopeWhere(r, []db.SQLWhere{db.NewSQLWhere(r.column(r.URL.ID.Name), string(r.URL.ID.Value))})
 		limit := db.NewSQLLimit(1)
 		for _, field := range f {
-			fields = append(fields, db.NewSQLField(r.DB.Escape(field.Name()), nil))
+			fields = append(fields, getFieldSelect(r.DB, field))
 		}
 		if body, err := r.DB.Select(table, fields, where, nil, nil, nil, limit, nil); err != nil {
 			return http.StatusInternalServerError, nil, nil, err
+		} else if body != nil && len(body) == 0 {
+			return http.StatusNotFound, nil, nil, fmt.Errorf("not found")
 		} else if body != nil && len(body) == 1 {
-			return http.StatusOK, nil, r.expand(r.Model, body[0]), err
+			head := getLastModified(r, body)
+			return http.StatusOK, head, r.format(r.Model, r.expand(r.Model, body[0])), err
 		}
 	}
 	return http.StatusInternalServerError, nil, nil, fmt.Errorf("unknown error")
@@ -236,33 +285,40 @@ func actionCreate(r *Request) (int, map[string]string, interface{}, error) {
 	if r.Model == nil {
 		return http.StatusInternalServerError, nil, nil, fmt.Errorf("missing model in %s", helper.TypeName(r.Controller))
 	}
-	if data, err := r.body(); err != nil {
+	if data, err := r.body(true); err != nil {
+		return err.Status(), nil, nil, err
+	} else if err := r.source(data, true); err != nil {
 		return err.Status(), nil, nil, err
 	} else if ctrl, ok := r.Controller.(ControllerWithID); ok && ctrl != nil {
 		table := db.NewSQLTable(r.Model.Table())
 		fields := make([]db.SQLField, 0)
+		for name, value := range getScopeValues(r) {
+			data[name] = value
+		}
 		for name, value := range data {
-			fields = append(fields, db.NewSQLField(r.DB.Escape(name), value))
+			fields = append(fields, db.NewSQLField(r.column(name), value))
 		}
 		if res, err := r.DB.Insert(table, fields); err != nil {
 			return http.StatusInternalServerError, nil, nil, err
 		} else if id := getModelField(r.Model, r.URL.ID.Name); id != nil {
 			if m, ok := res.(map[string]interface{}); ok && m != nil {
-				if id, ok := m[id.Name()]; ok && id != nil {
-					res = id
+				if v, ok := m[getFieldColumn(id)]; ok && v != nil {
+					res = v
+				} else if v, ok := m[id.Name()]; ok && v != nil {
+					res = v
 				}
 			}
 			if f := getModelFields(r.Model, r.User.Role(), usr.ALEVEL_READ); id.Validate(res) && f != nil && len(f) > 0 && res != nil {
 				table := db.NewSQLTable(r.Model.Table())
 				fields := make([]db.SQLField, 0)
-				where := []db.SQLWhere{db.NewSQLWhere(r.DB.Escape(id.Name()), res)}
+				where := getScopeWhere(r, []db.SQLWhere{db.NewSQLWhere(r.column(id.Name()), res)})
 				for _, field := range f {
-					fields = append(fields, db.NewSQLField(r.DB.Escape(field.Name()), nil))
+					fields = append(fields, getFieldSelect(r.DB, field))
 				}
 				if body, err := r.DB.Select(table, fields, where, nil, nil, nil, nil, nil); err != nil {
 					return http.StatusInternalServerError, nil, nil, err
 				} else if body != nil && len(body) == 1 {
-					return http.StatusCreated, nil, r.expand(r.Model, body[0]), err
+					return http.StatusCreated, nil, r.format(r.Model, r.expand(r.Model, body[0])), err
 				}
 			}
 		}
@@ -276,14 +332,20 @@ func actionUpdate(r *Request) (int, map[string]string, interface{}, error) {
 	} else if r.URL.ID.Value == nil {
 		return http.StatusBadRequest, nil, nil, fmt.Errorf("missing identifier")
 	}
-	if data, err := r.body(); err != nil {
+	if data, err := r.body(false); err != nil {
+		return err.Status(), nil, nil, err
+	} else if err := r.source(data, false); err != nil {
 		return err.Status(), nil, nil, err
 	} else if ctrl, ok := r.Controller.(ControllerWithID); ok && ctrl != nil {
 		table := db.NewSQLTable(r.Model.Table())
 		fields := make([]db.SQLField, 0)
-		where := []db.SQLWhere{db.NewSQLWhere(r.DB.Escape(r.URL.ID.Name), string(r.URL.ID.Value))}
+		where := getScopeWhere(r, []db.SQLWhere{db.NewSQLWhere(r.column(r.URL.ID.Name), string(r.URL.ID.Value))})
+		// the row can not be moved out of the scope
+		for name, value := range getScopeValues(r) {
+			data[name] = value
+		}
 		for name, value := range data {
-			fields = append(fields, db.NewSQLField(r.DB.Escape(name), value))
+			fields = append(fields, db.NewSQLField(r.column(name), value))
 		}
 		if err := r.DB.Update(table, fields, where); err != nil {
 			return http.StatusInternalServerError, nil, nil, err
@@ -291,12 +353,14 @@ func actionUpdate(r *Request) (int, map[string]string, interface{}, error) {
 			fields = make([]db.SQLField, 0)
 			limit := db.NewSQLLimit(1)
 			for _, field := range f {
-				fields = append(fields, db.NewSQLField(r.DB.Escape(field.Name()), nil))
+				fields = append(fields, getFieldSelect(r.DB, field))
 			}
 			if body, err := r.DB.Select(table, fields, where, nil, nil, nil, limit, nil); err != nil {
 				return http.StatusInternalServerError, nil, nil, err
+			} else if body != nil && len(body) == 0 {
+				return http.StatusNotFound, nil, nil, fmt.Errorf("not found")
 			} else if body != nil && len(body) == 1 {
-				return http.StatusAccepted, nil, r.expand(r.Model, body[0]), err
+				return http.StatusAccepted, nil, r.format(r.Model, r.expand(r.Model, body[0])), err
 			}
 		}
 	}
@@ -311,19 +375,21 @@ func actionDelete(r *Request) (int, map[string]string, interface{}, error) {
 	}
 	if ctrl, ok := r.Controller.(ControllerWithID); ok && ctrl != nil {
 		table := db.NewSQLTable(r.Model.Table())
-		where := []db.SQLWhere{db.NewSQLWhere(r.DB.Escape(r.URL.ID.Name), string(r.URL.ID.Value))}
+		where := getScopeWhere(r, []db.SQLWhere{db.NewSQLWhere(r.column(r.URL.ID.Name), string(r.URL.ID.Value))})
 		if f := getModelFields(r.Model, r.User.Role(), usr.ALEVEL_READ); f != nil && len(f) > 0 {
 			fields := make([]db.SQLField, 0)
 			limit := db.NewSQLLimit(1)
 			for _, field := range f {
-				fields = append(fields, db.NewSQLField(r.DB.Escape(field.Name()), nil))
+				fields = append(fields, getFieldSelect(r.DB, field))
 			}
 			if body, err := r.DB.Select(table, fields, where, nil, nil, nil, limit, nil); err != nil {
 				return http.StatusInternalServerError, nil, nil, err
+			} else if body != nil && len(body) == 0 {
+				return http.StatusNotFound, nil, nil, fmt.Errorf("not found")
 			} else if err := r.DB.Delete(table, where); err != nil {
 				return http.StatusInternalServerError, nil, nil, err
 			} else if body != nil && len(body) == 1 {
-				return http.StatusAccepted, nil, r.expand(r.Model, body[0]), err
+				return http.StatusAccepted, nil, r.format(r.Model, r.expand(r.Model, body[0])), err
 			}
 		}
 	}
@@ -333,10 +399,157 @@ func actionDelete(r *Request) (int, map[string]string, interface{}, error) {
 /***********************************************************************************************************************
  * helper
  */
+// scope conditions are grouped so that OR-separated user filters can not widen them
+func getScopeWhere(r *Request, where []db.SQLWhere) []db.SQLWhere {
+	scope := getScope(r)
+	if len(scope) == 0 {
+		return where
+	}
+	result := []db.SQLWhere{db.NewSQLWhereGroup(scope)}
+	if where != nil && len(where) > 0 {
+		result = append(result, db.NewSQLWhereGroup(where))
+	}
+	return result
+}
+
+// column values enforced on create (equality conditions of the scope)
+func getScopeValues(r *Request) map[string]interface{} {
+	result := make(map[string]interface{}, 0)
+	scope := getScope(r)
+	for _, w := range scope {
+		if w.Separator() == "OR" {
+			return make(map[string]interface{}, 0)
+		}
+	}
+	for _, w := range scope {
+		if v, ok := w.(*scopeWhere); ok && len(v.Instruction()) == 0 && !v.Negative() {
+			result[v.name] = v.Value()
+		}
+	}
+	return result
+}
+
+func getScope(r *Request) []db.SQLWhere {
+	where := make([]db.SQLWhere, 0)
+	if c, ok := r.Controller.(ControllerWithScope); ok && c != nil {
+		if w := c.Scope(r.User); w != nil {
+			where = append(where, w...)
+		}
+	}
+	if a, ok := getActionOrigin(r.action).(ActionWithScope); ok && a != nil {
+		if w := a.Scope(r.User); w != nil {
+			where = append(where, w...)
+		}
+	}
+	return newScopeWhere(r, where)
+}
+
+func newScopeWhere(r *Request, where []db.SQLWhere) []db.SQLWhere {
+	result := make([]db.SQLWhere, 0)
+	for _, w := range where {
+		if w == nil {
+			continue
+		}
+		item := scopeWhere{SQLWhere: w, name: w.Field(), value: w.Value()}
+		if v, ok := w.Value().([]db.SQLWhere); ok && strings.ToLower(w.Instruction()) == "group" {
+			item.value = newScopeWhere(r, v)
+		} else if len(item.name) > 0 {
+			item.field = r.column(item.name)
+		}
+		result = append(result, &item)
+	}
+	return result
+}
+
+// scope condition with escaped field name
+type scopeWhere struct {
+	name  string
+	field string
+	value interface{}
+	db.SQLWhere
+}
+
+func (this *scopeWhere) Field() string { return this.field }
+
+func (this *scopeWhere) Value() interface{} { return this.value }
+
+// custom action with middleware, optional interfaces (ActionWithID, ActionWithScope, ActionWithCache) are checked on the origin action
+type actionMiddleware struct {
+	middleware []Middleware
+	Action
+}
+
+func (this *actionMiddleware) Middleware() []Middleware {
+	return this.middleware
+}
+
+// custom action without the middleware wrapper
+func getActionOrigin(action Action) Action {
+	if a, ok := action.(*actionMiddleware); ok && a != nil {
+		return a.Action
+	}
+	return action
+}
+
+func getActionMiddleware(action Action) []Middleware {
+	if a, ok := action.(ActionWithMiddleware); ok && a != nil {
+		return a.Middleware()
+	}
+	return nil
+}
+
+func getActionCached(action Action) bool {
+	if a, ok := getActionOrigin(action).(ActionWithCache); ok && a != nil {
+		return a.Cached()
+	}
+	return false
+}
+
+// Last-Modified header by the latest value of the controller "updated at" field
+func getLastModified(r *Request, rows []map[string]interface{}) map[string]string {
+	c, ok := r.Controller.(ControllerWithLastModified)
+	if !ok || c == nil || rows == nil || len(rows) == 0 {
+		return nil
+	}
+	name := c.LastModified()
+	if len(name) == 0 {
+		return nil
+	}
+	result := time.Time{}
+	for _, row := range rows {
+		value, ok := row[name]
+		if !ok || value == nil {
+			continue
+		}
+		var t time.Time
+		switch v := value.(type) {
+		case time.Time:
+			t = v
+		case *time.Time:
+			if v != nil {
+				t = *v
+			}
+		case int64:
+			t = time.Unix(v, 0)
+		case []byte:
+			t = helper.ParseTime(string(v))
+		case string:
+			t = helper.ParseTime(v)
+		}
+		if t.After(result) {
+			result = t
+		}
+	}
+	if result.IsZero() {
+		return nil
+	}
+	return map[string]string{"Last-Modified": result.UTC().Format(http.TimeFormat)}
+}
+
 func getActionID(action Action) string {
 	name := ""
 	pattern := ""
-	if c, ok := action.(ActionWithID); ok == true && c != nil {
+	if c, ok := getActionOrigin(action).(ActionWithID); ok == true && c != nil {
 		name, pattern = c.Id()
 		if len(pattern) == 0 {
 			pattern = "[0-9]+"
@@ -0,0 +1,168 @@
+package grest
+
+import (
+	"github.com/prorochestvo/grest/db"
+	"github.com/prorochestvo/grest/usr"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActionLastModified(t *testing.T) {
+	updated := DATETIME("updated", "2006-01-02", usr.P_RO(usr.DefaultRole))
+	model := NewModel("users", []Field{INT64("id", usr.P_RO(usr.DefaultRole)), updated})
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testLastModifiedController{testController{path: "users", model: model, actions: []Action{NewActionPagination()}}})
+	driver.rows = []map[string]interface{}{{"id": int64(1), "updated": time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)}}
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("X-Timezone", "Asia/Tokyo")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("action[last-modified]: wrong status %d, body %s", w.Code, w.Body.String())
+	}
+	// by the raw value, not by the formatted date in the request time zone
+	if modified := w.Header().Get("Last-Modified"); modified != "Thu, 02 Jan 2020 15:04:05 GMT" {
+		t.Errorf("action[last-modified]: wrong Last-Modified %s", modified)
+	} else if !strings.Contains(w.Body.String(), `"updated":"2020-01-03"`) {
+		t.Errorf("action[last-modified]: wrong body %s", w.Body.String())
+	}
+	if len(w.Header().Get("ETag")) == 0 || w.Header().Get("Vary") != "X-Timezone" {
+		t.Errorf("action[last-modified]: wrong ETag «%s» or Vary «%s»", w.Header().Get("ETag"), w.Header().Get("Vary"))
+	}
+}
+
+func TestNewActionWithMiddleware(t *testing.T) {
+	value := &testAction{}
+	value.handler = func(r *Request) (int, map[string]string, interface{}, error) {
+		return http.StatusOK, nil, map[string]string{"name": r.URL.ID.Name, "value": string(r.URL.ID.Value)}, nil
+	}
+	calls := 0
+	wrapped := NewActionWithMiddleware(value, func(r *Request, next HandlerFunc) (int, map[string]string, interface{}, error) {
+		calls++
+		return next(r)
+	})
+	router := newTestRouter(newTestDriver(db.DialectPostgreSQL), &testController{path: "users", model: newTestModel(), actions: []Action{wrapped}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/login/demo", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("action[middleware]: wrong status %d, body %s", w.Code, w.Body.String())
+	} else if body := w.Body.String(); body != `{"name":"uid","value":"demo"}` {
+		t.Errorf("action[middleware]: wrong body %s", body)
+	} else if calls != 1 {
+		t.Errorf("action[middleware]: middleware called %d times", calls)
+	}
+	// options of the origin action
+	if !getActionCached(wrapped) {
+		t.Errorf("action[middleware]: ActionWithCache is lost")
+	}
+	if id := getActionID(wrapped); id != "{uid:[a-z]+}" {
+		t.Errorf("action[middleware]: wrong id %s", id)
+	}
+	r := &Request{route: newRoute(router, router.Migration.driver, router.controllers[0], wrapped)}
+	if scope := getScope(r); len(scope) != 1 {
+		t.Errorf("action[middleware]: ActionWithScope is lost")
+	}
+}
+
+func TestActionScope(t *testing.T) {
+	rw := usr.P_RW(usr.DefaultRole)
+	model := NewModel("users", []Field{INT64("id", usr.P_RO(usr.DefaultRole)), TEXT("login", rw), INT64("owner", rw)})
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testScopeController{testController{path: "users", model: model, actions: []Action{NewActionCreate(), NewActionUpdate()}}})
+	router.AccessControl.User = func(_ *Request) (usr.User, error) {
+		return usr.NewUser(int64(7), usr.DefaultRole), nil
+	}
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		rows   []map[string]interface{} // rows of the scoped select
+		status int
+	}{
+		{"create", http.MethodPost, "/users", `{"login":"demo"}`, []map[string]interface{}{{"id": int64(1)}}, http.StatusCreated},
+		{"create conflicting", http.MethodPost, "/users", `{"login":"demo","owner":9}`, []map[string]interface{}{{"id": int64(1)}}, http.StatusCreated},
+		{"update conflicting", http.MethodPut, "/users/1", `{"owner":9}`, []map[string]interface{}{{"id": int64(1)}}, http.StatusAccepted},
+		{"update out of scope", http.MethodPut, "/users/2", `{"login":"other"}`, []map[string]interface{}{}, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		driver.rows, driver.written, driver.filter = c.rows, nil, nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Errorf("action[scope:%s]: wrong status %d, body %s", c.name, w.Code, w.Body.String())
+		}
+		// the scope value overrides the body
+		if owner := driver.written["owner"]; owner != int64(7) {
+			t.Errorf("action[scope:%s]: wrong owner %v", c.name, owner)
+		}
+		// the row is updated only within the scope
+		if c.method == http.MethodPut {
+			query := db.NewSQLLinker().Delete(db.NewSQLTable("users"), driver.filter)
+			if !strings.Contains(query, `"owner" = 7`) || !strings.Contains(query, `"id" = '`+strings.TrimPrefix(c.path, "/users/")+`'`) {
+				t.Errorf("action[scope:%s]: wrong where %s", c.name, query)
+			}
+		}
+	}
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+// custom action with id, scope and cache
+type testAction struct {
+	handler HandlerFunc
+	roles   usr.Roles
+}
+
+func (this *testAction) Path() string {
+	return "login"
+}
+
+func (this *testAction) Methods() []string {
+	return []string{http.MethodGet}
+}
+
+func (this *testAction) WithID() bool {
+	return true
+}
+
+func (this *testAction) Roles() usr.Roles {
+	return this.roles
+}
+
+func (this *testAction) Run(r *Request) (int, map[string]string, interface{}, error) {
+	return this.handler(r)
+}
+
+func (this *testAction) Id() (string, string) {
+	return "uid", "[a-z]+"
+}
+
+func (this *testAction) Scope(user usr.User) []db.SQLWhere {
+	return []db.SQLWhere{db.NewSQLWhere("login", "demo")}
+}
+
+func (this *testAction) Cached() bool {
+	return true
+}
+
+type testScopeController struct {
+	testController
+}
+
+func (this *testScopeController) Scope(user usr.User) []db.SQLWhere {
+	return []db.SQLWhere{db.NewSQLWhere("owner", user.ID())}
+}
+
+type testLastModifiedController struct {
+	testController
+}
+
+func (this *testLastModifiedController) LastModified() string {
+	return "updated"
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/prorochestvo/grest/db"
 	"github.com/prorochestvo/grest/internal/helper"
 	"github.com/prorochestvo/grest/internal/mux"
+	"github.com/prorochestvo/grest/usr"
 	"regexp"
+	"strings"
 )
 
 type Controller interface {
@@ -28,6 +30,29 @@ type ControllerWithRouteCustom interface {
 	CustomRoutes(mux.Splitter)
 }
 
+// extra conditions by user, applied to every row read or changed by the standard actions
+type ControllerWithScope interface {
+	Scope(user usr.User) []db.SQLWhere
+	ControllerWithModel
+}
+
+type ControllerWithLastModified interface {
+	LastModified() (field string)
+	ControllerWithModel
+}
+
+// own middleware of all controller actions, inside the router middleware
+type ControllerWithMiddleware interface {
+	Middleware() []Middleware
+	Controller
+}
+
+// fixture rows loaded by Router.Seed, separate from migrations
+type ControllerWithSeeds interface {
+	Seeds() []db.Seed
+	Controller
+}
+
 type ControllerWithMigrations interface {
 	db.MigrationController
 	ControllerWithModel
@@ -55,6 +80,22 @@ func getControllerID(controller Controller) string {
 	return fmt.Sprintf("{%s:%s}", name, pattern)
 }
 
+// bare name of the identifier ("id" of "{id:[0-9]+}"), the primary key field of the model
+func getControllerIDName(controller Controller) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(getControllerID(controller), "{"), "}")
+	if pos := strings.Index(name, ":"); pos >= 0 {
+		name = name[:pos]
+	}
+	return name
+}
+
+func getControllerMiddleware(controller Controller) []Middleware {
+	if c, ok := controller.(ControllerWithMiddleware); ok && c != nil {
+		return c.Middleware()
+	}
+	return nil
+}
+
 func getControllerModel(controller Controller) Model {
 	var result Model = nil
 	if c, ok := controller.(ControllerWithModel); ok == true && c != nil {
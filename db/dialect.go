@@ -0,0 +1,174 @@
+package db
+
+import "strings"
+
+const (
+	DialectPostgreSQL = "postgres"
+	DialectSQLite     = "sqlite"
+	DialectMySQL      = "mysql"
+)
+
+type DriverWithDialect interface {
+	Dialect() string
+	Driver
+}
+
+// PostgreSQL by default
+func GetDialect(driver Driver) string {
+	if d, ok := driver.(DriverWithDialect); ok && d != nil {
+		if dialect := d.Dialect(); len(dialect) > 0 {
+			return dialect
+		}
+	}
+	return DialectPostgreSQL
+}
+
+// SQL column type by field type (text, int8 ... uint64, float32, float64, boolean, datetime, date, time, uuid, decimal, enum, bytes, array:TYPE)
+func SQLType(dialect, kind string) string {
+	if strings.HasPrefix(kind, "array:") {
+		switch dialect {
+		case DialectSQLite:
+			return "TEXT"
+		case DialectMySQL:
+			return "JSON"
+		}
+		return SQLType(dialect, strings.TrimPrefix(kind, "array:")) + "[]"
+	}
+	switch dialect {
+	case DialectSQLite:
+		switch kind {
+		case "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64":
+			return "INTEGER"
+		case "float32", "float64":
+			return "REAL"
+		case "boolean":
+			return "BOOLEAN"
+		case "datetime":
+			return "DATETIME"
+		case "date":
+			return "DATE"
+		case "time":
+			return "TIME"
+		case "decimal":
+			return "NUMERIC"
+		case "bytes":
+			return "BLOB"
+		}
+	case DialectMySQL:
+		switch kind {
+		case "int8":
+			return "TINYINT"
+		case "int16":
+			return "SMALLINT"
+		case "int32":
+			return "INT"
+		case "int64":
+			return "BIGINT"
+		case "uint8":
+			return "TINYINT UNSIGNED"
+		case "uint16":
+			return "SMALLINT UNSIGNED"
+		case "uint32":
+			return "INT UNSIGNED"
+		case "uint64":
+			return "BIGINT UNSIGNED"
+		case "float32":
+			return "FLOAT"
+		case "float64":
+			return "DOUBLE"
+		case "boolean":
+			return "BOOLEAN"
+		case "datetime":
+			return "DATETIME(6)"
+		case "date":
+			return "DATE"
+		case "time":
+			return "TIME"
+		case "uuid":
+			return "CHAR(36)"
+		case "decimal":
+			return "DECIMAL(65,30)"
+		case "bytes":
+			return "LONGBLOB"
+		}
+	default:
+		switch kind {
+		case "int8", "int16", "uint8":
+			return "SMALLINT"
+		case "int32", "uint16":
+			return "INTEGER"
+		case "int64", "uint32", "uint64":
+			return "BIGINT"
+		case "float32":
+			return "REAL"
+		case "float64":
+			return "DOUBLE PRECISION"
+		case "boolean":
+			return "BOOLEAN"
+		case "datetime":
+			return "TIMESTAMP WITH TIME ZONE"
+		case "date":
+			return "DATE"
+		case "time":
+			return "TIME"
+		case "uuid":
+			return "UUID"
+		case "decimal":
+			return "NUMERIC"
+		case "bytes":
+			return "BYTEA"
+		}
+	}
+	return "TEXT"
+}
+
+// both types store the same kind of value (e.g. INTEGER and int4, TEXT and character varying)
+func SQLTypeCompatible(dialect, expected, actual string) bool {
+	a := getSQLTypeFamily(expected)
+	b := getSQLTypeFamily(actual)
+	if a == b {
+		return true
+	}
+	// SQLite keeps any value in any column, only affinity matters
+	if dialect == DialectSQLite {
+		return (a == "real" && b == "integer") || (a == "numeric" && (b == "integer" || b == "real")) || b == "numeric" || b == "text" && (a == "uuid" || a == "datetime" || a == "date" || a == "time" || a == "array")
+	}
+	// MySQL keeps boolean as tinyint, uuid as char, array as json
+	if dialect == DialectMySQL {
+		return (a == "boolean" && b == "integer") || (a == "uuid" && b == "text") || (a == "array" && b == "json")
+	}
+	return false
+}
+
+func getSQLTypeFamily(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.HasSuffix(value, "[]") || strings.HasPrefix(value, "_") || value == "array" {
+		return "array"
+	}
+	if pos := strings.IndexAny(value, "( "); pos > 0 && !strings.HasPrefix(value, "double") && !strings.HasPrefix(value, "character") && !strings.HasPrefix(value, "timestamp") && !strings.HasPrefix(value, "time ") {
+		value = value[:pos]
+	}
+	switch {
+	case strings.Contains(value, "int") || strings.Contains(value, "serial"):
+		return "integer"
+	case value == "real" || value == "float" || value == "float4" || value == "float8" || strings.HasPrefix(value, "double"):
+		return "real"
+	case value == "numeric" || value == "decimal":
+		return "numeric"
+	case value == "boolean" || value == "bool":
+		return "boolean"
+	case strings.HasPrefix(value, "timestamp") || value == "datetime":
+		return "datetime"
+	case value == "date":
+		return "date"
+	case strings.HasPrefix(value, "time"):
+		return "time"
+	case value == "uuid":
+		return "uuid"
+	case value == "bytea" || strings.Contains(value, "blob") || strings.Contains(value, "binary"):
+		return "bytes"
+	case value == "json" || value == "jsonb":
+		return "json"
+	}
+	return "text"
+}
@@ -0,0 +1,32 @@
+package db
+
+import "testing"
+
+func TestSQLTypeCompatible(t *testing.T) {
+	for _, dialect := range []string{DialectPostgreSQL, DialectSQLite, DialectMySQL} {
+		for _, kind := range []string{"text", "int8", "int32", "int64", "uint64", "float32", "float64", "boolean", "datetime", "date", "time", "uuid", "decimal", "enum", "bytes", "array:text"} {
+			if expected := SQLType(dialect, kind); !SQLTypeCompatible(dialect, expected, expected) {
+				t.Errorf("db[%s]: type «%s» («%s») is not compatible with itself", dialect, kind, expected)
+			}
+		}
+	}
+	// introspected names
+	pairs := [][]string{
+		{DialectPostgreSQL, "BIGINT", "integer"},
+		{DialectPostgreSQL, "TEXT", "character varying"},
+		{DialectPostgreSQL, "TIMESTAMP WITH TIME ZONE", "timestamp without time zone"},
+		{DialectPostgreSQL, "DOUBLE PRECISION", "double precision"},
+		{DialectPostgreSQL, "TEXT[]", "ARRAY"},
+		{DialectMySQL, "BOOLEAN", "tinyint"},
+		{DialectMySQL, "CHAR(36)", "char"},
+		{DialectSQLite, "DATETIME", "TEXT"},
+	}
+	for _, pair := range pairs {
+		if !SQLTypeCompatible(pair[0], pair[1], pair[2]) {
+			t.Errorf("db[%s]: type «%s» must be compatible with «%s»", pair[0], pair[1], pair[2])
+		}
+	}
+	if SQLTypeCompatible(DialectPostgreSQL, "BIGINT", "text") {
+		t.Errorf("db[%s]: type «%s» must not be compatible with «%s»", DialectPostgreSQL, "BIGINT", "text")
+	}
+}
@@ -1,5 +1,7 @@
 package db
 
+import "time"
+
 type Driver interface {
 	Select(table SQLTable, fields []SQLField, where []SQLWhere, groupBy []SQLGroupBy, having []SQLHaving, orderBy []SQLOrderBy, limit SQLLimit, offset SQLOffset) (rows []map[string]interface{}, err error)
 	Insert(table SQLTable, fields []SQLField) (res interface{}, err error)
@@ -10,3 +12,21 @@ type Driver interface {
 
 	Escape(value string) string
 }
+
+// driver with own lock held across connections (e.g. PostgreSQL pg_advisory_lock on a dedicated connection)
+type DriverWithLock interface {
+	Lock(name string, timeout time.Duration) (unlock func() error, err error)
+	Driver
+}
+
+// driver with transactions, Go function migrations run inside one
+type DriverWithTx interface {
+	Begin() (Tx, error)
+	Driver
+}
+
+type Tx interface {
+	Commit() error
+	Rollback() error
+	Driver
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var migrationFileName = regexp.MustCompile(`^([0-9]+_[^.]+)\.(up|down)\.sql$`)
+
+// NNNN_name.up.sql / NNNN_name.down.sql files of the directory, version is NNNN_name
+func NewMigrationsFromDir(dir string) ([]Migration, error) {
+	return NewMigrationsFromFS(http.Dir(dir))
+}
+
+// NNNN_name.up.sql / NNNN_name.down.sql files of the file system root, version is NNNN_name
+func NewMigrationsFromFS(fs http.FileSystem) ([]Migration, error) {
+	root, err := fs.Open("/")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = root.Close() }()
+	files, err := root.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	up := make(map[string]string, 0)
+	down := make(map[string]string, 0)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		matches := migrationFileName.FindStringSubmatch(file.Name())
+		if matches == nil {
+			continue
+		}
+		body, err := readMigrationFile(fs, file.Name())
+		if err != nil {
+			return nil, err
+		}
+		if matches[2] == "up" {
+			up[matches[1]] = body
+		} else {
+			down[matches[1]] = body
+		}
+	}
+	result := make([]Migration, 0, len(up))
+	for version, sql := range up {
+		result = append(result, NewMigration(version, sql, down[version]))
+	}
+	for version := range down {
+		if _, ok := up[version]; !ok {
+			return nil, fmt.Errorf("migration \"%s\" has no up file", version)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version() < result[j].Version() })
+	return result, nil
+}
+
+func readMigrationFile(fs http.FileSystem, name string) (string, error) {
+	file, err := fs.Open("/" + name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	body, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewMigrationsFromDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	files := map[string]string{
+		"0002_add_email.up.sql":      "ALTER TABLE users ADD COLUMN email TEXT;\n",
+		"0002_add_email.down.sql":    "ALTER TABLE users DROP COLUMN email;",
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0003_seed.up.sql":           "INSERT INTO users (id) VALUES (1);",
+		"readme.md":                  "not a migration",
+	}
+	for name, body := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	migrations, err := NewMigrationsFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("db[migration-file]: wrong count %d, must be 3", len(migrations))
+	}
+	if m := migrations[0]; m.Version() != "0001_create_users" || m.Up() != files["0001_create_users.up.sql"] || m.Down() != files["0001_create_users.down.sql"] {
+		t.Errorf("db[migration-file]: wrong migration «%s»", m.Version())
+	}
+	if m := migrations[1]; m.Version() != "0002_add_email" || m.Up() != "ALTER TABLE users ADD COLUMN email TEXT;" {
+		t.Errorf("db[migration-file]: wrong migration «%s» (Up: %q)", m.Version(), m.Up())
+	}
+	if m := migrations[2]; m.Version() != "0003_seed" || len(m.Down()) != 0 {
+		t.Errorf("db[migration-file]: wrong migration «%s» (Down: %q)", m.Version(), m.Down())
+	}
+	// down without up
+	if err = ioutil.WriteFile(filepath.Join(dir, "0004_orphan.down.sql"), []byte("SELECT 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewMigrationsFromDir(dir); err == nil {
+		t.Errorf("db[migration-file]: missing up file must fail")
+	}
+}
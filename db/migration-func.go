@@ -0,0 +1,48 @@
+package db
+
+// migration with Go logic (backfill, data transform), Up/Down SQL are empty
+type FuncMigration interface {
+	UpFunc(driver Driver) error
+	DownFunc(driver Driver) error
+	Migration
+}
+
+func NewFuncMigration(version string, up, down func(driver Driver) error) FuncMigration {
+	result := funcMigration{}
+	result.version = version
+	result.up = up
+	result.down = down
+	return &result
+}
+
+type funcMigration struct {
+	version string
+	up      func(driver Driver) error
+	down    func(driver Driver) error
+}
+
+func (this *funcMigration) Version() string {
+	return this.version
+}
+
+func (this *funcMigration) Up() string {
+	return ""
+}
+
+func (this *funcMigration) Down() string {
+	return ""
+}
+
+func (this *funcMigration) UpFunc(driver Driver) error {
+	if this.up == nil {
+		return nil
+	}
+	return this.up(driver)
+}
+
+func (this *funcMigration) DownFunc(driver Driver) error {
+	if this.down == nil {
+		return nil
+	}
+	return this.down(driver)
+}
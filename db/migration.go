@@ -1,5 +1,10 @@
 package db
 
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
 type Migration interface {
 	Version() string
 	Up() string
@@ -35,3 +40,45 @@ func (this *migration) Up() string {
 func (this *migration) Down() string {
 	return this.down
 }
+
+// sha256 of up and down sql
+func MigrationChecksum(migration Migration) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(migration.Up()+"\x00"+migration.Down())))
+}
+
+// migrations of the controller are namespaced by Namespace() instead of the controller path
+type MigrationControllerWithNamespace interface {
+	Namespace() string
+	MigrationController
+}
+
+// migration applied after the given namespaces ("users") or versions of namespaces ("users@m0002")
+type MigrationWithDependencies interface {
+	Dependencies() []string
+	Migration
+}
+
+func MigrationAfter(migration Migration, dependencies ...string) Migration {
+	if m, ok := migration.(FuncMigration); ok && m != nil {
+		return &funcMigrationAfter{FuncMigration: m, dependencies: dependencies}
+	}
+	return &migrationAfter{Migration: migration, dependencies: dependencies}
+}
+
+type migrationAfter struct {
+	Migration
+	dependencies []string
+}
+
+func (this *migrationAfter) Dependencies() []string {
+	return this.dependencies
+}
+
+type funcMigrationAfter struct {
+	FuncMigration
+	dependencies []string
+}
+
+func (this *funcMigrationAfter) Dependencies() []string {
+	return this.dependencies
+}
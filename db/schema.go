@@ -0,0 +1,197 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SQLColumn interface {
+	Name() string
+	Type() string
+	Nullable() bool
+	Primary() bool
+}
+
+func NewSQLColumn(name, kind string, nullable, primary bool) SQLColumn {
+	result := sqlColumn{}
+	result.name = name
+	result.kind = strings.ToLower(kind)
+	result.nullable = nullable
+	result.primary = primary
+	return &result
+}
+
+type sqlColumn struct {
+	name     string
+	kind     string
+	nullable bool
+	primary  bool
+}
+
+func (this *sqlColumn) Name() string {
+	return this.name
+}
+
+func (this *sqlColumn) Type() string {
+	return this.kind
+}
+
+func (this *sqlColumn) Nullable() bool {
+	return this.nullable
+}
+
+func (this *sqlColumn) Primary() bool {
+	return this.primary
+}
+
+/**
+ * Database schema introspection
+ */
+type Schema interface {
+	Tables() ([]string, error)
+	Columns(table string) ([]SQLColumn, error)
+}
+
+// driver with own introspection
+type DriverWithSchema interface {
+	Schema() Schema
+	Driver
+}
+
+// driver schema or information_schema (PostgreSQL, MySQL) / pragma (SQLite) queries
+func GetSchema(driver Driver) Schema {
+	if d, ok := driver.(DriverWithSchema); ok && d != nil {
+		if s := d.Schema(); s != nil {
+			return s
+		}
+	}
+	return NewSchema(driver)
+}
+
+func NewSchema(driver Driver) Schema {
+	result := schema{}
+	result.driver = driver
+	result.dialect = GetDialect(driver)
+	return &result
+}
+
+type schema struct {
+	driver  Driver
+	dialect string
+}
+
+func (this *schema) Tables() ([]string, error) {
+	var rows []map[string]interface{}
+	var err error
+	switch this.dialect {
+	case DialectSQLite:
+		rows, err = this.driver.Select(NewSQLTable("sqlite_master"), []SQLField{NewSQLField("name", nil)}, []SQLWhere{NewSQLWhere("type", "table")}, nil, nil, nil, nil, nil)
+	case DialectMySQL:
+		rows, err = this.driver.Select(NewSQLTable("information_schema.tables"), []SQLField{NewSQLField("table_name AS name", nil)}, []SQLWhere{NewSQLWhere("table_schema", SQLRaw("DATABASE()")), NewSQLWhere("table_type", "BASE TABLE")}, nil, nil, nil, nil, nil)
+	default:
+		rows, err = this.driver.Select(NewSQLTable("information_schema.tables"), []SQLField{NewSQLField("table_name AS name", nil)}, []SQLWhere{NewSQLWhere("table_schema", SQLRaw("current_schema()")), NewSQLWhere("table_type", "BASE TABLE")}, nil, nil, nil, nil, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, row := range rows {
+		name := getSchemaText(row, "name")
+		if len(name) == 0 || strings.HasPrefix(name, "sqlite_") {
+			continue
+		}
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (this *schema) Columns(table string) ([]SQLColumn, error) {
+	result := make([]SQLColumn, 0)
+	switch this.dialect {
+	case DialectSQLite:
+		rows, err := this.driver.Select(NewSQLTable(fmt.Sprintf("pragma_table_info(%s)", SQLEscape(table))), []SQLField{NewSQLField("name", nil), NewSQLField("type", nil), NewSQLField(`"notnull" AS required`, nil), NewSQLField("pk AS primary_key", nil)}, nil, nil, nil, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			primary := getSchemaText(row, "primary_key") != "0"
+			result = append(result, NewSQLColumn(getSchemaText(row, "name"), getSchemaText(row, "type"), getSchemaText(row, "required") == "0" && !primary, primary))
+		}
+	default:
+		where := []SQLWhere{NewSQLWhere("table_schema", SQLRaw("current_schema()")), NewSQLWhere("table_name", table)}
+		if this.dialect == DialectMySQL {
+			where = []SQLWhere{NewSQLWhere("table_schema", SQLRaw("DATABASE()")), NewSQLWhere("table_name", table)}
+		}
+		// formatted types, data_type is ARRAY / USER-DEFINED (PostgreSQL) or varchar without length (MySQL)
+		fields := []SQLField{NewSQLField("column_name AS name", nil), NewSQLField("data_type AS type", nil), NewSQLField("udt_name AS udt", nil), NewSQLField("is_nullable AS nullable", nil)}
+		if this.dialect == DialectMySQL {
+			fields = []SQLField{NewSQLField("column_name AS name", nil), NewSQLField("column_type AS type", nil), NewSQLField("is_nullable AS nullable", nil)}
+		}
+		rows, err := this.driver.Select(NewSQLTable("information_schema.columns"), fields, where, nil, nil, []SQLOrderBy{NewSQLOrderBy("ordinal_position", "ASC")}, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		primary, err := this.primary(table)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			name := getSchemaText(row, "name")
+			_, pk := primary[name]
+			result = append(result, NewSQLColumn(name, getSchemaType(row), strings.ToUpper(getSchemaText(row, "nullable")) == "YES", pk))
+		}
+	}
+	return result, nil
+}
+
+func (this *schema) primary(table string) (map[string]bool, error) {
+	schema := SQLRaw("current_schema()")
+	if this.dialect == DialectMySQL {
+		schema = SQLRaw("DATABASE()")
+	}
+	from := NewSQLTable("information_schema.table_constraints tc JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema AND tc.table_name = kcu.table_name")
+	where := []SQLWhere{NewSQLWhere("tc.table_schema", schema), NewSQLWhere("tc.table_name", table), NewSQLWhere("tc.constraint_type", "PRIMARY KEY")}
+	rows, err := this.driver.Select(from, []SQLField{NewSQLField("kcu.column_name AS name", nil)}, where, nil, nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, 0)
+	for _, row := range rows {
+		result[getSchemaText(row, "name")] = true
+	}
+	return result, nil
+}
+
+func getSchemaText(row map[string]interface{}, name string) string {
+	value, ok := row[name]
+	if !ok {
+		// MySQL returns upper case names of information_schema columns
+		if value, ok = row[strings.ToUpper(name)]; !ok {
+			return ""
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// type usable in DDL: int4[] instead of ARRAY, the enum name instead of USER-DEFINED
+func getSchemaType(row map[string]interface{}) string {
+	kind := getSchemaText(row, "type")
+	udt := getSchemaText(row, "udt")
+	switch {
+	case strings.EqualFold(kind, "ARRAY") && strings.HasPrefix(udt, "_"):
+		return udt[1:] + "[]"
+	case strings.EqualFold(kind, "USER-DEFINED") && len(udt) > 0:
+		return udt
+	}
+	return kind
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// fixture rows of the table, upserted by key columns
+type Seed interface {
+	Table() string
+	Key() []string
+	Rows() []map[string]interface{}
+	Tags() []string
+}
+
+// rows by column names, tags are environments (dev, test, demo), a seed without tags is loaded in every environment
+func NewSeed(table string, key []string, rows []map[string]interface{}, tags ...string) Seed {
+	result := seed{}
+	result.table = table
+	result.key = key
+	result.rows = rows
+	result.tags = tags
+	return &result
+}
+
+type seed struct {
+	table string
+	key   []string
+	rows  []map[string]interface{}
+	tags  []string
+}
+
+func (this *seed) Table() string {
+	return this.table
+}
+
+func (this *seed) Key() []string {
+	return this.key
+}
+
+func (this *seed) Rows() []map[string]interface{} {
+	return this.rows
+}
+
+func (this *seed) Tags() []string {
+	return this.tags
+}
+
+// *.json files of the directory in name order (see NewSeedsFromFS)
+func NewSeedsFromDir(dir string) ([]Seed, error) {
+	return NewSeedsFromFS(http.Dir(dir))
+}
+
+/*
+ * *.json files of the file system root in name order, each file is an object or an array of objects:
+ *   {"table": "users", "key": ["login"], "tags": ["dev", "demo"], "rows": [{"login": "demo", "role": "user"}]}
+ */
+func NewSeedsFromFS(fs http.FileSystem) ([]Seed, error) {
+	root, err := fs.Open("/")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = root.Close() }()
+	files, err := root.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	result := make([]Seed, 0)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		body, err := readMigrationFile(fs, file.Name())
+		if err != nil {
+			return nil, err
+		}
+		// editors may save UTF-8 files with BOM
+		body = strings.TrimSpace(strings.TrimPrefix(body, "\uFEFF"))
+		items := make([]seedFile, 0)
+		decoder := json.NewDecoder(bytes.NewBufferString(body))
+		decoder.UseNumber()
+		if strings.HasPrefix(body, "[") {
+			err = decoder.Decode(&items)
+		} else {
+			item := seedFile{}
+			err = decoder.Decode(&item)
+			items = append(items, item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("seed \"%s\": %s", file.Name(), err.Error())
+		}
+		for _, item := range items {
+			if len(item.Table) == 0 || len(item.Key) == 0 {
+				return nil, fmt.Errorf("seed \"%s\": table and key are required", file.Name())
+			}
+			for _, row := range item.Rows {
+				for column, value := range row {
+					row[column] = getSeedValue(value)
+				}
+			}
+			result = append(result, NewSeed(item.Table, item.Key, item.Rows, item.Tags...))
+		}
+	}
+	return result, nil
+}
+
+/***********************************************************************************************************************
+ * helper
+ */
+
+type seedFile struct {
+	Table string                   `json:"table"`
+	Key   []string                 `json:"key"`
+	Tags  []string                 `json:"tags"`
+	Rows  []map[string]interface{} `json:"rows"`
+}
+
+// json numbers as int64 or float64, nested values as json text
+func getSeedValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		} else if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	return value
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSeedsFromDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	files := map[string]string{
+		"01_users.json":    `{"table": "users", "key": ["login"], "tags": ["dev", "demo"], "rows": [{"login": "demo", "age": 42, "rate": 1.5, "meta": {"a": 1}}]}`,
+		"02_sessions.json": "\uFEFF\n  " + `[{"table": "sessions", "key": ["id"], "rows": [{"id": 1}]}, {"table": "tokens", "key": ["id"], "rows": []}]`,
+		"readme.md":        "not a seed",
+	}
+	for name, body := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seeds, err := NewSeedsFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 3 {
+		t.Fatalf("db[seed-file]: wrong count %d, must be 3", len(seeds))
+	}
+	if s := seeds[0]; s.Table() != "users" || len(s.Tags()) != 2 || len(s.Rows()) != 1 {
+		t.Errorf("db[seed-file]: wrong seed «%s»", s.Table())
+	} else if row := s.Rows()[0]; row["age"] != int64(42) || row["rate"] != 1.5 || row["meta"] != `{"a":1}` || row["login"] != "demo" {
+		t.Errorf("db[seed-file]: wrong row %v", row)
+	}
+	if s := seeds[1]; s.Table() != "sessions" || len(s.Tags()) != 0 {
+		t.Errorf("db[seed-file]: wrong seed «%s»", s.Table())
+	}
+	// key is required
+	if err = ioutil.WriteFile(filepath.Join(dir, "03_wrong.json"), []byte(`{"table": "users"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewSeedsFromDir(dir); err == nil {
+		t.Errorf("db[seed-file]: seed without key must fail")
+	}
+}
@@ -1,13 +1,28 @@
 package db
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 )
 
+// SQL expression, never escaped (do not use for request values)
+type SQLRaw string
+
+// PostgreSQL literal (see SQLEscapeDialect)
 func SQLEscape(value interface{}) string {
+	return SQLEscapeDialect(DialectPostgreSQL, value)
+}
+
+// literal of the dialect, differs for []byte: '\x..' bytea (PostgreSQL), X'..' blob (SQLite, MySQL)
+func SQLEscapeDialect(dialect string, value interface{}) string {
 	result := ""
-	if v, ok := value.(string); ok {
+	if value == nil {
+		result = "NULL"
+	} else if v, ok := value.(SQLRaw); ok {
+		result = string(v)
+	} else if v, ok := value.(string); ok {
 		v = strings.ReplaceAll(v, "'", "''")
 		result = fmt.Sprintf("'%s'", v)
 	} else if v, ok := value.(int); ok {
@@ -34,12 +49,34 @@ func SQLEscape(value interface{}) string {
 		result = fmt.Sprintf("%f", v)
 	} else if v, ok := value.(float64); ok {
 		result = fmt.Sprintf("%f", v)
+	} else if v, ok := value.(time.Time); ok {
+		result = fmt.Sprintf("'%s'", v.Format(time.RFC3339Nano))
 	} else if v, ok := value.(bool); ok {
 		if v == true {
 			result = "TRUE"
 		} else {
 			result = "FALSE"
 		}
+	} else if v, ok := value.([]byte); ok && (dialect == DialectSQLite || dialect == DialectMySQL) {
+		result = fmt.Sprintf("X'%s'", hex.EncodeToString(v))
+	} else if v, ok := value.([]byte); ok {
+		// PostgreSQL bytea hex format
+		result = fmt.Sprintf("'\\x%s'", hex.EncodeToString(v))
+	} else if v, ok := value.([]interface{}); ok {
+		// PostgreSQL array literal
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if item == nil {
+				items = append(items, "NULL")
+			} else if s, ok := item.(string); ok {
+				s = strings.ReplaceAll(s, `\`, `\\`)
+				s = strings.ReplaceAll(s, `"`, `\"`)
+				items = append(items, fmt.Sprintf(`"%s"`, s))
+			} else {
+				items = append(items, strings.Trim(SQLEscapeDialect(dialect, item), "'"))
+			}
+		}
+		result = SQLEscapeDialect(dialect, fmt.Sprintf("{%s}", strings.Join(items, ",")))
 	}
 	return result
 }
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestSQLEscape(t *testing.T) {
+	values := []struct {
+		Value    interface{}
+		Expected string
+	}{
+		{nil, "NULL"},
+		{"it's", "'it''s'"},
+		{int64(-5), "-5"},
+		{true, "TRUE"},
+		{[]byte{0xde, 0xad}, `'\xdead'`},
+		{[]interface{}{"a", `b"c`, nil, int64(1)}, `'{"a","b\"c",NULL,1}'`},
+	}
+	for _, v := range values {
+		if result := SQLEscape(v.Value); result != v.Expected {
+			t.Errorf("db[escape]: wrong value «%s», must be «%s»", result, v.Expected)
+		}
+	}
+}
+
+func TestSQLEscapeDialect(t *testing.T) {
+	values := []struct {
+		Dialect  string
+		Value    interface{}
+		Expected string
+	}{
+		{DialectPostgreSQL, []byte{0xde, 0xad}, `'\xdead'`},
+		{DialectSQLite, []byte{0xde, 0xad}, `X'dead'`},
+		{DialectMySQL, []byte{0xde, 0xad}, `X'dead'`},
+		{DialectSQLite, "it's", "'it''s'"},
+	}
+	for _, v := range values {
+		if result := SQLEscapeDialect(v.Dialect, v.Value); result != v.Expected {
+			t.Errorf("db[escape:%s]: wrong value «%s», must be «%s»", v.Dialect, result, v.Expected)
+		}
+	}
+	if result := NewSQLLinker(DialectSQLite).Insert(NewSQLTable("files"), []SQLField{NewSQLField("data", []byte{0x01})}); result != "INSERT INTO files (data)\nVALUES (X'01');" {
+		t.Errorf("db[escape:linker]: wrong query «%s»", result)
+	}
+}
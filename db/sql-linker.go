@@ -12,12 +12,22 @@ type SQLLinker interface {
 	Delete(table SQLTable, where []SQLWhere) string
 }
 
-func NewSQLLinker() SQLLinker {
+// values are escaped by the dialect (PostgreSQL by default, see SQLEscapeDialect)
+func NewSQLLinker(dialect ...string) SQLLinker {
 	result := sqlLinker{}
+	result.dialect = DialectPostgreSQL
+	if len(dialect) > 0 && len(dialect[0]) > 0 {
+		result.dialect = dialect[0]
+	}
 	return &result
 }
 
 type sqlLinker struct {
+	dialect string
+}
+
+func (this *sqlLinker) escape(value interface{}) string {
+	return SQLEscapeDialect(this.dialect, value)
 }
 
 func (this *sqlLinker) Select(table SQLTable, fields []SQLField, where []SQLWhere, groupBy []SQLGroupBy, having []SQLHaving, orderBy []SQLOrderBy, limit SQLLimit, offset SQLOffset) string {
@@ -67,10 +77,10 @@ func (this *sqlLinker) Insert(table SQLTable, fields []SQLField) string {
 		for i, field := range fields {
 			if i == 0 {
 				f += fmt.Sprintf("%s", field.Name())
-				v += fmt.Sprintf("%s", SQLEscape(field.Value()))
+				v += fmt.Sprintf("%s", this.escape(field.Value()))
 			} else {
 				f += fmt.Sprintf(", %s", field.Name())
-				v += fmt.Sprintf(", %s", SQLEscape(field.Value()))
+				v += fmt.Sprintf(", %s", this.escape(field.Value()))
 			}
 		}
 	}
@@ -82,9 +92,9 @@ func (this *sqlLinker) Update(table SQLTable, fields []SQLField, where []SQLWher
 	if fields != nil && len(fields) > 0 {
 		for i, field := range fields {
 			if i == 0 {
-				f += fmt.Sprintf("%s = %s", field.Name(), SQLEscape(field.Value()))
+				f += fmt.Sprintf("%s = %s", field.Name(), this.escape(field.Value()))
 			} else {
-				f += fmt.Sprintf(", %s = %s", field.Name(), SQLEscape(field.Value()))
+				f += fmt.Sprintf(", %s = %s", field.Name(), this.escape(field.Value()))
 			}
 		}
 	}
@@ -115,59 +125,7 @@ func (this *sqlLinker) parser(value interface{}) string {
 	result := ""
 	// where
 	if where, ok := value.([]SQLWhere); ok && where != nil && len(where) > 0 {
-		tmp := ""
-		for _, w := range where {
-			q := ""
-			if o := strings.ToLower(w.Instruction()); o == "between" {
-				if v, ok := w.Value().([]interface{}); ok && v != nil && len(v) == 2 {
-					q = fmt.Sprintf("%s BETWEEN %s AND %s", w.Field(), SQLEscape(v[0]), SQLEscape(v[1]))
-					if w.Negative() {
-						q = fmt.Sprintf("NOT(%s)", q)
-					}
-				}
-			} else if o == "in" {
-				if v, ok := w.Value().([]interface{}); ok && v != nil && len(v) > 0 {
-					tmp := make([]string, 0)
-					for _, val := range v {
-						tmp = append(tmp, SQLEscape(val))
-					}
-					q = fmt.Sprintf("%s IN (%s)", w.Field(), strings.Join(tmp, ", "))
-					if w.Negative() {
-						q = fmt.Sprintf("NOT(%s)", q)
-					}
-				}
-			} else if o == "like" {
-				q = fmt.Sprintf("%s LIKE %s", w.Field(), SQLEscape(w.Value()))
-			} else if o == "is_null" {
-				if w.Negative() {
-					q = fmt.Sprintf("IS NOT NULL %s", w.Field())
-				} else {
-					q = fmt.Sprintf("IS NULL %s", w.Field())
-				}
-			} else if o == "<" {
-				q = fmt.Sprintf("%s < %s", w.Field(), SQLEscape(w.Value()))
-			} else if o == "<=" {
-				q = fmt.Sprintf("%s <= %s", w.Field(), SQLEscape(w.Value()))
-			} else if o == ">" {
-				q = fmt.Sprintf("%s > %s", w.Field(), SQLEscape(w.Value()))
-			} else if o == ">=" {
-				q = fmt.Sprintf("%s >= %s", w.Field(), SQLEscape(w.Value()))
-			} else if len(o) == 0 {
-				if w.Negative() {
-					q = fmt.Sprintf("%s <> %s", w.Field(), SQLEscape(w.Value()))
-				} else {
-					q = fmt.Sprintf("%s = %s", w.Field(), SQLEscape(w.Value()))
-				}
-			}
-			if s := w.Separator(); len(tmp) == 0 {
-				tmp = fmt.Sprintf("(%s)", q)
-			} else if s == "OR" {
-				tmp = fmt.Sprintf("%s %s (%s)", tmp, s, q)
-			} else if s == "AND" {
-				tmp = fmt.Sprintf("%s %s (%s)", tmp, s, q)
-			}
-		}
-		if len(tmp) > 0 {
+		if tmp := this.conditions(where); len(tmp) > 0 {
 			result = fmt.Sprintf("WHERE %s", tmp)
 		}
 	} else
@@ -195,32 +153,32 @@ func (this *sqlLinker) parser(value interface{}) string {
 			q := ""
 			if o := strings.ToLower(w.Instruction()); o == "between" {
 				if v, ok := w.Value().([]interface{}); ok && v != nil && len(v) == 2 {
-					q = fmt.Sprintf("%s BETWEEN %s AND %s", w.Field(), SQLEscape(v[0]), SQLEscape(v[1]))
+					q = fmt.Sprintf("%s BETWEEN %s AND %s", w.Field(), this.escape(v[0]), this.escape(v[1]))
 					if w.Negative() {
 						q = fmt.Sprintf("NOT(%s)", q)
 					}
 				}
 			} else if o == "like" {
-				q = fmt.Sprintf("%s LIKE %s", w.Field(), SQLEscape(w.Value()))
+				q = fmt.Sprintf("%s LIKE %s", w.Field(), this.escape(w.Value()))
 			} else if o == "is_null" {
 				if w.Negative() {
-					q = fmt.Sprintf("IS NOT NULL %s", w.Field())
+					q = fmt.Sprintf("%s IS NOT NULL", w.Field())
 				} else {
-					q = fmt.Sprintf("IS NULL %s", w.Field())
+					q = fmt.Sprintf("%s IS NULL", w.Field())
 				}
 			} else if o == "<" {
-				q = fmt.Sprintf("%s < %s", w.Field(), SQLEscape(w.Value()))
+				q = fmt.Sprintf("%s < %s", w.Field(), this.escape(w.Value()))
 			} else if o == "<=" {
-				q = fmt.Sprintf("%s <= %s", w.Field(), SQLEscape(w.Value()))
+				q = fmt.Sprintf("%s <= %s", w.Field(), this.escape(w.Value()))
 			} else if o == ">" {
-				q = fmt.Sprintf("%s > %s", w.Field(), SQLEscape(w.Value()))
+				q = fmt.Sprintf("%s > %s", w.Field(), this.escape(w.Value()))
 			} else if o == ">=" {
-				q = fmt.Sprintf("%s >= %s", w.Field(), SQLEscape(w.Value()))
+				q = fmt.Sprintf("%s >= %s", w.Field(), this.escape(w.Value()))
 			} else if len(o) == 0 {
 				if w.Negative() {
-					q = fmt.Sprintf("%s <> %s", w.Field(), SQLEscape(w.Value()))
+					q = fmt.Sprintf("%s <> %s", w.Field(), this.escape(w.Value()))
 				} else {
-					q = fmt.Sprintf("%s = %s", w.Field(), SQLEscape(w.Value()))
+					q = fmt.Sprintf("%s = %s", w.Field(), this.escape(w.Value()))
 				}
 			}
 			if s := w.Separator(); len(tmp) == 0 {
@@ -266,3 +224,72 @@ func (this *sqlLinker) parser(value interface{}) string {
 	}
 	return result
 }
+
+func (this *sqlLinker) conditions(where []SQLWhere) string {
+	tmp := ""
+	for _, w := range where {
+		q := ""
+		if o := strings.ToLower(w.Instruction()); o == "between" {
+			if v, ok := w.Value().([]interface{}); ok && v != nil && len(v) == 2 {
+				q = fmt.Sprintf("%s BETWEEN %s AND %s", w.Field(), this.escape(v[0]), this.escape(v[1]))
+				if w.Negative() {
+					q = fmt.Sprintf("NOT(%s)", q)
+				}
+			}
+		} else if o == "in" {
+			if v, ok := w.Value().([]interface{}); ok && v != nil && len(v) > 0 {
+				tmp := make([]string, 0)
+				for _, val := range v {
+					tmp = append(tmp, this.escape(val))
+				}
+				q = fmt.Sprintf("%s IN (%s)", w.Field(), strings.Join(tmp, ", "))
+				if w.Negative() {
+					q = fmt.Sprintf("NOT(%s)", q)
+				}
+			}
+		} else if o == "group" {
+			if v, ok := w.Value().([]SQLWhere); ok && v != nil && len(v) > 0 {
+				q = this.conditions(v)
+				if w.Negative() {
+					q = fmt.Sprintf("NOT(%s)", q)
+				}
+			}
+		} else if o == "like" {
+			q = fmt.Sprintf("%s LIKE %s", w.Field(), this.escape(w.Value()))
+		} else if o == "is_null" {
+			if w.Negative() {
+				q = fmt.Sprintf("%s IS NOT NULL", w.Field())
+			} else {
+				q = fmt.Sprintf("%s IS NULL", w.Field())
+			}
+		} else if o == "<" {
+			q = fmt.Sprintf("%s < %s", w.Field(), this.escape(w.Value()))
+		} else if o == "<=" {
+			q = fmt.Sprintf("%s <= %s", w.Field(), this.escape(w.Value()))
+		} else if o == ">" {
+			q = fmt.Sprintf("%s > %s", w.Field(), this.escape(w.Value()))
+		} else if o == ">=" {
+			q = fmt.Sprintf("%s >= %s", w.Field(), this.escape(w.Value()))
+		} else if len(o) == 0 && w.Value() == nil {
+			if w.Negative() {
+				q = fmt.Sprintf("%s IS NOT NULL", w.Field())
+			} else {
+				q = fmt.Sprintf("%s IS NULL", w.Field())
+			}
+		} else if len(o) == 0 {
+			if w.Negative() {
+				q = fmt.Sprintf("%s <> %s", w.Field(), this.escape(w.Value()))
+			} else {
+				q = fmt.Sprintf("%s = %s", w.Field(), this.escape(w.Value()))
+			}
+		}
+		if s := w.Separator(); len(tmp) == 0 {
+			tmp = fmt.Sprintf("(%s)", q)
+		} else if s == "OR" {
+			tmp = fmt.Sprintf("%s %s (%s)", tmp, s, q)
+		} else if s == "AND" {
+			tmp = fmt.Sprintf("%s %s (%s)", tmp, s, q)
+		}
+	}
+	return tmp
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestSQLLinkerWhereGroup(t *testing.T) {
+	scope := []SQLWhere{NewSQLWhere("owner_id", int64(7))}
+	where := []SQLWhere{NewSQLWhere("name", "a"), NewSQLWhere("name", "b", "OR")}
+	query := NewSQLLinker().Select(NewSQLTable("users"), []SQLField{NewSQLField("*", nil)}, []SQLWhere{NewSQLWhereGroup(scope), NewSQLWhereGroup(where)}, nil, nil, nil, nil, nil)
+	if expected := "SELECT *\nFROM users\nWHERE ((owner_id = 7)) AND ((name = 'a') OR (name = 'b'));"; query != expected {
+		t.Errorf("db[linker-group]: wrong query «%s», must be «%s»", query, expected)
+	}
+}
+
+func TestSQLLinkerNull(t *testing.T) {
+	linker := NewSQLLinker()
+	if query, expected := linker.Insert(NewSQLTable("users"), []SQLField{NewSQLField("name", nil)}), "INSERT INTO users (name)\nVALUES (NULL);"; query != expected {
+		t.Errorf("db[linker-null]: wrong query «%s», must be «%s»", query, expected)
+	}
+	if query, expected := linker.Update(NewSQLTable("users"), []SQLField{NewSQLField("name", nil)}, []SQLWhere{NewSQLWhere("id", int64(1))}), "UPDATE users\nSET name = NULL\nWHERE (id = 1);"; query != expected {
+		t.Errorf("db[linker-null]: wrong query «%s», must be «%s»", query, expected)
+	}
+	if query, expected := linker.Delete(NewSQLTable("users"), []SQLWhere{NewSQLWhere("name", nil), NewSQLWhere("login", nil, "is_null")}), "DELETE FROM users\nWHERE (name IS NULL) AND (login IS NULL);"; query != expected {
+		t.Errorf("db[linker-null]: wrong query «%s», must be «%s»", query, expected)
+	}
+}
@@ -171,8 +171,8 @@ func SQLParserEx(r *http.Request, parsers map[string]func(value string) (interfa
  *   !FIELD_NAME[]=VALUE                  // NOT(field IN (value))
  *   :between[FIELD_NAME][]=VALUE         // field BETWEEN value:first AND value:last
  *   :!between[FIELD_NAME][]=VALUE        // NOT(field BETWEEN value:first AND value:last)
- *   :is_null[FIELD_NAME]                 // IS NULL field
- *   :!is_null[FIELD_NAME]                // IS NOT NULL field
+ *   :is_null[FIELD_NAME]                 // field IS NULL
+ *   :!is_null[FIELD_NAME]                // field IS NOT NULL
  *   :like[FIELD_NAME]=VALUE              // field LIKE value
  *   :!like[FIELD_NAME]=VALUE             // NOT(field LIKE value)
  *   :cmp_be[FIELD_NAME]=VALUE            // field >= value
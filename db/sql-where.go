@@ -56,3 +56,17 @@ func (this *sqlWhere) Value() interface{} {
 func (this *sqlWhere) Negative() bool {
 	return this.negative
 }
+
+// (where[0] AND/OR where[1] ...) as single condition
+func NewSQLWhereGroup(where []SQLWhere, instruction ...string) SQLWhere {
+	result := sqlWhere{instruction: "group", separator: "AND"}
+	if instruction != nil && len(instruction) > 0 {
+		for _, o := range instruction {
+			if s := strings.Trim(strings.ToUpper(o), "\t\n\r "); s == "OR" || s == "AND" {
+				result.separator = s
+			}
+		}
+	}
+	result.value = where
+	return &result
+}
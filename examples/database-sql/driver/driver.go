@@ -18,7 +18,7 @@ type driver struct {
 }
 
 func (this *driver) Select(table db.SQLTable, fields []db.SQLField, where []db.SQLWhere, groupBy []db.SQLGroupBy, having []db.SQLHaving, orderBy []db.SQLOrderBy, limit db.SQLLimit, offset db.SQLOffset) ([]map[string]interface{}, error) {
-	query := db.NewSQLLinker().Select(table, fields, where, groupBy, having, orderBy, limit, offset)
+	query := db.NewSQLLinker(db.DialectSQLite).Select(table, fields, where, groupBy, having, orderBy, limit, offset)
 	if rows, err := this.DB.Query(query); err != nil && err != sql.ErrNoRows {
 		return nil, err
 	} else if rows != nil {
@@ -49,7 +49,7 @@ func (this *driver) Select(table db.SQLTable, fields []db.SQLField, where []db.S
 }
 
 func (this *driver) Insert(table db.SQLTable, fields []db.SQLField) (interface{}, error) {
-	query := db.NewSQLLinker().Insert(table, fields)
+	query := db.NewSQLLinker(db.DialectSQLite).Insert(table, fields)
 	if res, err := this.DB.Exec(query); err != nil && err != sql.ErrNoRows {
 		return 0, err
 	} else if res == nil {
@@ -63,7 +63,7 @@ func (this *driver) Insert(table db.SQLTable, fields []db.SQLField) (interface{}
 }
 
 func (this *driver) Update(table db.SQLTable, fields []db.SQLField, where []db.SQLWhere) error {
-	query := db.NewSQLLinker().Update(table, fields, where)
+	query := db.NewSQLLinker(db.DialectSQLite).Update(table, fields, where)
 	if _, err := this.DB.Exec(query); err != nil && err != sql.ErrNoRows {
 		return err
 	}
@@ -71,7 +71,7 @@ func (this *driver) Update(table db.SQLTable, fields []db.SQLField, where []db.S
 }
 
 func (this *driver) Delete(table db.SQLTable, where []db.SQLWhere) error {
-	query := db.NewSQLLinker().Delete(table, where)
+	query := db.NewSQLLinker(db.DialectSQLite).Delete(table, where)
 	if _, err := this.DB.Exec(query); err != nil && err != sql.ErrNoRows {
 		return err
 	}
@@ -103,3 +103,7 @@ func (this *driver) Exec(query ...string) error {
 func (this *driver) Escape(value string) string {
   return fmt.Sprintf(`"%s"`, value)
 }
+
+func (this *driver) Dialect() string {
+	return db.DialectSQLite
+}
@@ -497,7 +497,7 @@ func TestJSONRouter(t *testing.T) {
 		t.Errorf("grest[module-sql-editor]: modules: wrong response status (%d)", code)
 	} else if h, ok := head["Content-Type"]; !ok || strings.Index(strings.Join(h, " "), "text/plain") < 0 {
 		t.Errorf("grest[module-sql-editor]: modules: %s (%s)", "wrong content-type header", strings.Join(h, " "))
-	} else if s := fmt.Sprintf("SELECT *\nFROM %s\n%s;", UserSession.Model().Table(), "WHERE (NOT(ID BETWEEN '2' AND '3')) AND (name LIKE 'admin') AND (name LIKE 'support') AND (IS NULL is_enabled) AND (NOT(id IN ('7', '6', '5'))) AND (uid = '1')\nORDER BY id DESC\nLIMIT 1\nOFFSET 123"); string(body) != s {
+	} else if s := fmt.Sprintf("SELECT *\nFROM %s\n%s;", UserSession.Model().Table(), "WHERE (NOT(ID BETWEEN '2' AND '3')) AND (name LIKE 'admin') AND (name LIKE 'support') AND (is_enabled IS NULL) AND (NOT(id IN ('7', '6', '5'))) AND (uid = '1')\nORDER BY id DESC\nLIMIT 1\nOFFSET 123"); string(body) != s {
 		t.Errorf("grest[module-sql-editor]: modules: wrong sql response (%s)\n\n%s", string(body), string(s))
 	} else
   if code, _, body, err := httpQuery(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/api/docs/sql/editor/%s%s", HTTPPort, User.Model().Table(), "?:!in[id][]=1&:!in[id][]=2&:!in[id][]=3"), map[string]string{"Authorization": fmt.Sprintf("%d", RoleAdmin)}, nil); err != nil {
@@ -6,6 +6,7 @@ import (
 	"github.com/jackc/pgx"
 	"github.com/prorochestvo/grest/db"
 	"strings"
+	"time"
 )
 
 // PostgreSQL driver
@@ -117,3 +118,30 @@ func (this *driver) Exec(query ...string) error {
 func (this *driver) Escape(value string) string {
 	return fmt.Sprintf(`"%s"`, value)
 }
+
+// session advisory lock on a dedicated connection, held until unlock (see db.DriverWithLock)
+func (this *driver) Lock(name string, timeout time.Duration) (func() error, error) {
+	conn, err := this.ConnPool.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked := false
+		if err = conn.QueryRow("SELECT pg_try_advisory_lock(hashtext($1));", name).Scan(&locked); err != nil {
+			this.ConnPool.Release(conn)
+			return nil, err
+		} else if locked {
+			break
+		} else if time.Now().After(deadline) {
+			this.ConnPool.Release(conn)
+			return nil, fmt.Errorf("lock timeout: \"%s\" is locked by another instance", name)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return func() error {
+		defer this.ConnPool.Release(conn)
+		_, err := conn.Exec("SELECT pg_advisory_unlock(hashtext($1));", name)
+		return err
+	}, nil
+}
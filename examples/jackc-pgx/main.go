@@ -74,15 +74,16 @@ CREATE TABLE "%s" (
 );`, this.table()),
 			fmt.Sprintf(`DROP TABLE "%s";`, this.table()),
 		),
-		db.NewMigration(
-			fmt.Sprintf(`"m0002-init_table_%s`, this.table()),
-			fmt.Sprintf(`
-INSERT INTO "%s" ("login", "password", "name")
-VALUES ('admin','pass-admin','ADMIN'),
-       ('support','pass-support','SUPPORT'),
-       ('user','pass-user','USER');`, this.table()),
-			fmt.Sprintf(`DELETE FROM "%s";`, this.table()),
-		),
+	}
+}
+
+func (this *users) Seeds() []db.Seed {
+	return []db.Seed{
+		db.NewSeed(this.table(), []string{"login"}, []map[string]interface{}{
+			{"login": "admin", "password": "pass-admin", "name": "ADMIN"},
+			{"login": "support", "password": "pass-support", "name": "SUPPORT"},
+			{"login": "user", "password": "pass-user", "name": "USER"},
+		}),
 	}
 }
 
@@ -127,6 +128,10 @@ func main() {
 	if err := router.Migration.Up(); err != nil {
 		log.Fatalf("GREST MIGRATION: %s", err.Error())
 	}
+	// seed db
+	if err := router.Seed.Run(); err != nil {
+		log.Fatalf("GREST SEED: %s", err.Error())
+	}
 	// revise db
 	if err := router.Migration.Revise(); err != nil {
 		log.Println("revise:")
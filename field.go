@@ -1,21 +1,29 @@
 package grest
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"github.com/prorochestvo/grest/internal"
+	"github.com/prorochestvo/grest/internal/helper"
 	"github.com/prorochestvo/grest/usr"
+	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
 func FIELD(name string, parser func(value string) (interface{}, error), permission ...usr.Permission) FieldEx {
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castPlain(parser))
 }
 
 func TEXT(name string, permission ...usr.Permission) FieldEx {
 	parser := func(value string) (interface{}, error) {
 		return value, nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castString(parser)).format(formatText).typed("text")
 }
 
 func INT8(name string, permission ...usr.Permission) FieldEx {
@@ -26,7 +34,7 @@ func INT8(name string, permission ...usr.Permission) FieldEx {
 		}
 		return int8(i), nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("int8")
 }
 
 func INT16(name string, permission ...usr.Permission) FieldEx {
@@ -37,7 +45,7 @@ func INT16(name string, permission ...usr.Permission) FieldEx {
 		}
 		return int16(i), nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("int16")
 }
 
 func INT32(name string, permission ...usr.Permission) FieldEx {
@@ -48,7 +56,7 @@ func INT32(name string, permission ...usr.Permission) FieldEx {
 		}
 		return int32(i), nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("int32")
 }
 
 func INT64(name string, permission ...usr.Permission) FieldEx {
@@ -59,7 +67,7 @@ func INT64(name string, permission ...usr.Permission) FieldEx {
 		}
 		return i, nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("int64")
 }
 
 func UINT8(name string, permission ...usr.Permission) FieldEx {
@@ -70,7 +78,7 @@ func UINT8(name string, permission ...usr.Permission) FieldEx {
 		}
 		return uint8(u), nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("uint8")
 }
 
 func UINT16(name string, permission ...usr.Permission) FieldEx {
@@ -81,7 +89,7 @@ func UINT16(name string, permission ...usr.Permission) FieldEx {
 		}
 		return uint16(u), nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("uint16")
 }
 
 func UINT32(name string, permission ...usr.Permission) FieldEx {
@@ -92,7 +100,7 @@ func UINT32(name string, permission ...usr.Permission) FieldEx {
 		}
 		return uint32(u), nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("uint32")
 }
 
 func UINT64(name string, permission ...usr.Permission) FieldEx {
@@ -103,7 +111,7 @@ func UINT64(name string, permission ...usr.Permission) FieldEx {
 		}
 		return u, nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("uint64")
 }
 
 func FLOAT32(name string, permission ...usr.Permission) FieldEx {
@@ -114,7 +122,7 @@ func FLOAT32(name string, permission ...usr.Permission) FieldEx {
 		}
 		return float32(f), nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("float32")
 }
 
 func FLOAT64(name string, permission ...usr.Permission) FieldEx {
@@ -125,7 +133,7 @@ func FLOAT64(name string, permission ...usr.Permission) FieldEx {
 		}
 		return f, nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castNumber(parser)).format(formatNumber(parser)).typed("float64")
 }
 
 func BOOLEAN(name string, permission ...usr.Permission) FieldEx {
@@ -136,7 +144,7 @@ func BOOLEAN(name string, permission ...usr.Permission) FieldEx {
 		}
 		return b, nil
 	}
-	return newField(name, parser, nil, permission...)
+	return newField(name, parser, nil, permission...).cast(castBool(parser)).format(formatBool).typed("boolean")
 }
 
 func DATETIME(name string, format string, permission ...usr.Permission) FieldEx {
@@ -147,7 +155,162 @@ func DATETIME(name string, format string, permission ...usr.Permission) FieldEx
 		}
 		return b.Format(format), nil
 	}
-	return newField(name, parser, nil, permission...)
+	formatter := func(value interface{}, location *time.Location) interface{} {
+		return formatTime(format, value, location)
+	}
+	return newField(name, parser, nil, permission...).cast(castString(parser)).format(formatter).typed("datetime")
+}
+
+func UUID(name string, permission ...usr.Permission) FieldEx {
+	rx := regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	parser := func(value string) (interface{}, error) {
+		if !rx.MatchString(value) {
+			return nil, fmt.Errorf("must be an uuid")
+		}
+		return strings.ToLower(value), nil
+	}
+	return newField(name, parser, nil, permission...).cast(castString(parser)).format(formatUUID).typed("uuid")
+}
+
+// string-backed exact number
+func DECIMAL(name string, permission ...usr.Permission) FieldEx {
+	rx := regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+	parser := func(value string) (interface{}, error) {
+		if !rx.MatchString(value) {
+			return nil, fmt.Errorf("must be a decimal number")
+		}
+		return value, nil
+	}
+	caster := func(value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case json.Number:
+			return parser(v.String())
+		case string:
+			return parser(v)
+		}
+		return nil, fmt.Errorf("must be a decimal number")
+	}
+	return newField(name, parser, nil, permission...).cast(caster).format(formatDecimal).typed("decimal")
+}
+
+func DATE(name string, permission ...usr.Permission) FieldEx {
+	return newDateTimeField(name, "date", "2006-01-02", permission...)
+}
+
+func TIME(name string, permission ...usr.Permission) FieldEx {
+	return newDateTimeField(name, "time", "15:04:05", permission...)
+}
+
+func ENUM(name string, values []string, permission ...usr.Permission) FieldEx {
+	parser := func(value string) (interface{}, error) {
+		for _, v := range values {
+			if v == value {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+	return newField(name, parser, nil, permission...).cast(castString(parser)).format(formatText).typed("enum")
+}
+
+// comma separated values in url query, JSON array in request body
+func ARRAY(name string, of Field, permission ...usr.Permission) FieldEx {
+	parser := func(value string) (interface{}, error) {
+		result := make([]interface{}, 0)
+		if len(value) == 0 {
+			return result, nil
+		}
+		for _, item := range strings.Split(value, ",") {
+			v, err := of.Parser(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}
+	caster := func(value interface{}) (interface{}, error) {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("must be an array")
+		}
+		result := make([]interface{}, 0, len(items))
+		for i, item := range items {
+			if f, ok := of.(FieldWithCast); ok && f != nil {
+				v, err := f.Cast(item)
+				if err != nil {
+					return nil, fmt.Errorf("item %d %s", i, err.Error())
+				}
+				item = v
+			}
+			result = append(result, item)
+		}
+		return result, nil
+	}
+	formatter := func(value interface{}, location *time.Location) interface{} {
+		return formatArray(of, value, location)
+	}
+	return newField(name, parser, nil, permission...).cast(caster).format(formatter).typed("array:" + getFieldType(of))
+}
+
+// base64 on the wire
+func BYTES(name string, permission ...usr.Permission) FieldEx {
+	parser := func(value string) (interface{}, error) {
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a base64 string")
+		}
+		return b, nil
+	}
+	return newField(name, parser, nil, permission...).cast(castString(parser)).format(formatBytes).typed("bytes")
+}
+
+func newDateTimeField(name string, kind string, layout string, permission ...usr.Permission) *field {
+	parser := func(value string) (interface{}, error) {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(layout), nil
+	}
+	// date or time without time zone
+	formatter := func(value interface{}, _ *time.Location) interface{} {
+		return formatTime(layout, value, nil)
+	}
+	return newField(name, parser, nil, permission...).cast(castString(parser)).format(formatter).typed(kind)
+}
+
+/**
+ * Field response value, location is the time zone requested by client (nil if not set)
+ */
+type FieldFormatter func(value interface{}, location *time.Location) interface{}
+
+/**
+ * Field server side value
+ */
+type FieldSource func(r *Request) (interface{}, error)
+
+// identifier of the current user
+func SourceUserID() FieldSource {
+	return func(r *Request) (interface{}, error) {
+		if r.User == nil {
+			return nil, fmt.Errorf("missing user")
+		}
+		return r.User.ID(), nil
+	}
+}
+
+// current time (UTC)
+func SourceNow() FieldSource {
+	return func(_ *Request) (interface{}, error) {
+		return time.Now().UTC(), nil
+	}
+}
+
+func SourceConst(value interface{}) FieldSource {
+	return func(_ *Request) (interface{}, error) {
+		return value, nil
+	}
 }
 
 func EXPAND(name string, internalKeys []Field, externalModel Model, externalKeys []Field, limit int64, role ...usr.Role) ExtraField {
@@ -172,7 +335,62 @@ type Field interface {
 }
 
 type FieldEx interface {
+	// validator receives the cast value (see FieldWithCast): int64 for INT64, string for TEXT, float64 for plain FIELD numbers, etc.
 	SetValidate(value func(interface{}) bool)
+	SetRules(value ...Rule)
+	SetRequired(value bool)
+	SetNullable(value bool)
+	SetFormatter(value FieldFormatter)
+	SetColumn(value string)
+	SetType(value string)
+	SetExpression(value string)
+	SetOnCreate(value FieldSource)
+	SetOnUpdate(value FieldSource)
+	Field
+}
+
+// required: must be present in request body on create
+// nullable: accepts explicit null
+type FieldWithOptions interface {
+	Required() bool
+	Nullable() bool
+	Field
+}
+
+// database column (or read-only SQL expression) of the field, Name() is used in API only
+type FieldWithColumn interface {
+	Column() string
+	Expression() string
+	Field
+}
+
+// value type: int8 ... uint64, float32, float64, decimal, text, boolean, datetime, date, time, uuid, enum, bytes, array:TYPE
+type FieldWithType interface {
+	Type() string
+	Field
+}
+
+// type check and conversion of request body value
+type FieldWithCast interface {
+	Cast(value interface{}) (interface{}, error)
+	Field
+}
+
+// response value of database value
+type FieldWithFormat interface {
+	Format(value interface{}, location *time.Location) interface{}
+	Field
+}
+
+type FieldWithRules interface {
+	Rules() []Rule
+	Field
+}
+
+// server side values, override request body
+type FieldWithSource interface {
+	OnCreate() FieldSource
+	OnUpdate() FieldSource
 	Field
 }
 
@@ -190,8 +408,18 @@ func newField(name string, parser func(value string) (interface{}, error), valid
 
 type field struct {
 	name       string
+	column     string
+	expression string
+	kind       string
 	parser     func(value string) (interface{}, error)
+	caster     func(value interface{}) (interface{}, error)
+	formatter  FieldFormatter
 	validator  func(interface{}) bool
+	rules      []Rule
+	required   bool
+	nullable   bool
+	onCreate   FieldSource
+	onUpdate   FieldSource
 	permission []usr.Permission
 }
 
@@ -199,6 +427,38 @@ func (this *field) Name() string {
 	return this.name
 }
 
+func (this *field) Column() string {
+	if len(this.column) == 0 {
+		return this.name
+	}
+	return this.column
+}
+
+func (this *field) SetColumn(value string) {
+	this.column = value
+}
+
+func (this *field) Expression() string {
+	return this.expression
+}
+
+func (this *field) SetExpression(value string) {
+	this.expression = value
+}
+
+func (this *field) Type() string {
+	return this.kind
+}
+
+func (this *field) SetType(value string) {
+	this.kind = value
+}
+
+func (this *field) typed(value string) *field {
+	this.kind = value
+	return this
+}
+
 func (this *field) Validate(value interface{}) bool {
 	if this.validator == nil {
 		return true
@@ -210,6 +470,49 @@ func (this *field) SetValidate(value func(interface{}) bool) {
 	this.validator = value
 }
 
+func (this *field) Rules() []Rule {
+	if this.rules == nil {
+		return make([]Rule, 0)
+	}
+	return this.rules
+}
+
+func (this *field) SetRules(value ...Rule) {
+	this.rules = value
+}
+
+func (this *field) Required() bool {
+	return this.required
+}
+
+func (this *field) SetRequired(value bool) {
+	this.required = value
+}
+
+func (this *field) Nullable() bool {
+	return this.nullable
+}
+
+func (this *field) SetNullable(value bool) {
+	this.nullable = value
+}
+
+func (this *field) OnCreate() FieldSource {
+	return this.onCreate
+}
+
+func (this *field) SetOnCreate(value FieldSource) {
+	this.onCreate = value
+}
+
+func (this *field) OnUpdate() FieldSource {
+	return this.onUpdate
+}
+
+func (this *field) SetOnUpdate(value FieldSource) {
+	this.onUpdate = value
+}
+
 func (this *field) Parser(value string) (interface{}, error) {
 	if this.parser == nil {
 		return nil, nil
@@ -217,6 +520,34 @@ func (this *field) Parser(value string) (interface{}, error) {
 	return this.parser(value)
 }
 
+func (this *field) Cast(value interface{}) (interface{}, error) {
+	if this.caster == nil || value == nil {
+		return value, nil
+	}
+	return this.caster(value)
+}
+
+func (this *field) cast(value func(value interface{}) (interface{}, error)) *field {
+	this.caster = value
+	return this
+}
+
+func (this *field) Format(value interface{}, location *time.Location) interface{} {
+	if this.formatter == nil || value == nil {
+		return value
+	}
+	return this.formatter(value, location)
+}
+
+func (this *field) SetFormatter(value FieldFormatter) {
+	this.formatter = value
+}
+
+func (this *field) format(value FieldFormatter) *field {
+	this.formatter = value
+	return this
+}
+
 func (this *field) Roles(accessLevel ...internal.AccessLevel) usr.Roles {
 	result := make([]usr.Role, 0)
 	if accessLevel == nil || len(accessLevel) == 0 {
@@ -240,6 +571,208 @@ func (this *field) Roles(accessLevel ...internal.AccessLevel) usr.Roles {
 	return result
 }
 
+/**
+ * Field casters
+ */
+func castString(parser func(value string) (interface{}, error)) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		if v, ok := value.(string); ok {
+			return parser(v)
+		}
+		return nil, fmt.Errorf("must be a string")
+	}
+}
+
+// lossless for integers decoded as json.Number
+func castNumber(parser func(value string) (interface{}, error)) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case json.Number:
+			return parser(v.String())
+		case float64:
+			return parser(strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		return nil, fmt.Errorf("must be a number")
+	}
+}
+
+func castBool(parser func(value string) (interface{}, error)) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		if v, ok := value.(bool); ok {
+			return parser(strconv.FormatBool(v))
+		}
+		return nil, fmt.Errorf("must be a boolean")
+	}
+}
+
+// value of the plain field: numbers by the parser without float64 rounding (int64 or float64 if the parser is nil),
+// objects and arrays as decoded, their numbers as json.Number
+func castPlain(parser func(value string) (interface{}, error)) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		v, ok := value.(json.Number)
+		if !ok {
+			return value, nil
+		} else if parser != nil {
+			return parser(v.String())
+		} else if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	}
+}
+
+/**
+ * Field formatters
+ */
+func formatText(value interface{}, _ *time.Location) interface{} {
+	if v, ok := value.([]byte); ok {
+		return string(v)
+	}
+	return value
+}
+
+func formatNumber(parser func(value string) (interface{}, error)) FieldFormatter {
+	return func(value interface{}, _ *time.Location) interface{} {
+		var text string
+		switch v := value.(type) {
+		case []byte:
+			text = string(v)
+		case string:
+			text = v
+		case fmt.Stringer:
+			text = v.String()
+		default:
+			return value
+		}
+		if result, err := parser(text); err == nil {
+			return result
+		}
+		return value
+	}
+}
+
+func formatBool(value interface{}, _ *time.Location) interface{} {
+	switch v := value.(type) {
+	case int64:
+		return v != 0
+	case []byte:
+		return formatBool(string(v), nil)
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func formatUUID(value interface{}, _ *time.Location) interface{} {
+	var b []byte = nil
+	switch v := value.(type) {
+	case [16]byte:
+		b = v[:]
+	case []byte:
+		if len(v) != 16 {
+			return strings.ToLower(string(v))
+		}
+		b = v
+	case string:
+		return strings.ToLower(v)
+	default:
+		return value
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func formatDecimal(value interface{}, _ *time.Location) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+func formatTime(layout string, value interface{}, location *time.Location) interface{} {
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return value
+		}
+		t = *v
+	case []byte:
+		return formatTime(layout, string(v), location)
+	case string:
+		if tmp, err := time.Parse(layout, v); err == nil {
+			t = tmp
+		} else if tmp := helper.ParseTime(v); !tmp.IsZero() {
+			t = tmp
+		} else {
+			return v
+		}
+	default:
+		return value
+	}
+	if location != nil {
+		t = t.In(location)
+	}
+	return t.Format(layout)
+}
+
+func formatBytes(value interface{}, _ *time.Location) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case string:
+		// PostgreSQL bytea hex format
+		if b, err := hex.DecodeString(strings.TrimPrefix(v, `\x`)); strings.HasPrefix(v, `\x`) && err == nil {
+			return base64.StdEncoding.EncodeToString(b)
+		}
+		return base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	return value
+}
+
+func formatArray(of Field, value interface{}, location *time.Location) interface{} {
+	items := make([]interface{}, 0)
+	if v, ok := value.([]byte); ok {
+		value = string(v)
+	}
+	if v, ok := value.(string); ok {
+		// PostgreSQL array literal {a,"b c",NULL}
+		for _, item := range helper.ParseSQLArray(v) {
+			if item == nil {
+				items = append(items, nil)
+			} else if i, err := of.Parser(*item); err == nil {
+				items = append(items, i)
+			} else {
+				items = append(items, *item)
+			}
+		}
+	} else if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, rv.Index(i).Interface())
+		}
+	} else {
+		return value
+	}
+	if f, ok := of.(FieldWithFormat); ok && f != nil {
+		for i, item := range items {
+			items[i] = f.Format(item, location)
+		}
+	}
+	return items
+}
+
 /**
  * Extra field
  */
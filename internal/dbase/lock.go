@@ -0,0 +1,80 @@
+package dbase
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"time"
+)
+
+type Lock interface {
+	Acquire(name, owner string, expiry time.Duration) (bool, error)
+	Refresh(name, owner string, expiry time.Duration) error
+	Release(name, owner string) error
+}
+
+func NewLock(driver db.Driver, table string) Lock {
+	result := lock{}
+	result.driver = driver
+	result.table = table
+	return &result
+}
+
+type lock struct {
+	table  string
+	driver db.Driver
+}
+
+func (this *lock) init() error {
+	return this.driver.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s ( name TEXT NOT NULL, owner TEXT NOT NULL, expire_time INTEGER NOT NULL, PRIMARY KEY(name) );`, this.table))
+}
+
+// захватить блокировку, false если блокировка занята другим владельцем
+func (this *lock) Acquire(name, owner string, expiry time.Duration) (bool, error) {
+	if err := this.init(); err != nil {
+		return false, err
+	}
+	now := time.Now().Unix()
+	// просроченная блокировка
+	if err := this.driver.Delete(db.NewSQLTable(this.table), []db.SQLWhere{db.NewSQLWhere("name", name), db.NewSQLWhere("expire_time", now, "<")}); err != nil {
+		return false, err
+	}
+	if current, err := this.owner(name); err != nil {
+		return false, err
+	} else if len(current) > 0 {
+		return current == owner, nil
+	}
+	if err := this.driver.Exec(fmt.Sprintf("INSERT INTO %s (name, owner, expire_time) VALUES (%s, %s, %d);", this.table, db.SQLEscape(name), db.SQLEscape(owner), now+int64(expiry/time.Second))); err != nil {
+		// другой владелец успел раньше
+		if current, e := this.owner(name); e == nil && len(current) > 0 && current != owner {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// продлить блокировку
+func (this *lock) Refresh(name, owner string, expiry time.Duration) error {
+	return this.driver.Update(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("expire_time", time.Now().Unix()+int64(expiry/time.Second))}, []db.SQLWhere{db.NewSQLWhere("name", name), db.NewSQLWhere("owner", owner)})
+}
+
+// освободить блокировку
+func (this *lock) Release(name, owner string) error {
+	return this.driver.Delete(db.NewSQLTable(this.table), []db.SQLWhere{db.NewSQLWhere("name", name), db.NewSQLWhere("owner", owner)})
+}
+
+func (this *lock) owner(name string) (string, error) {
+	rows, err := this.driver.Select(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("owner", nil)}, []db.SQLWhere{db.NewSQLWhere("name", name)}, nil, nil, nil, db.NewSQLLimit(1), nil)
+	if err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		switch v := row["owner"].(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		}
+	}
+	return "", nil
+}
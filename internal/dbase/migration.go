@@ -3,15 +3,46 @@ package dbase
 import (
 	"fmt"
 	"github.com/prorochestvo/grest/db"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
 	"time"
 )
 
 type Migration interface {
+	Init() error
+	Ready() bool
 	Version() (string, error)
 	History() ([]string, error)
 	Exists(version string) (bool, error)
-	Append(version, sql string) error
+	Checksums() (map[string]string, error)
+	Records() ([]Record, error)
+	Append(version, sql, checksum string, start time.Time) error
+	Repair(version, checksum string) error
 	Remove(version, sql string) error
+	Attempt(version, direction string, start time.Time, err error) error
+	Attempts(version string) ([]Attempt, error)
+}
+
+type Record struct {
+	Version   string
+	ApplyTime int64 // unix seconds
+	ApplyNano int64 // unix nanoseconds, 0 for records before
+	Duration  int64 // nanoseconds
+	Host      string
+	User      string
+	Checksum  string
+}
+
+type Attempt struct {
+	Version   string
+	Direction string // up or down
+	ApplyNano int64
+	Duration  int64
+	Host      string
+	User      string
+	Error     string // empty on success
 }
 
 func NewMigration(driver db.Driver, table string) Migration {
@@ -26,10 +57,42 @@ type migration struct {
 	driver db.Driver
 }
 
+// создать хеш таблицу и журнал попыток, добавить колонки в таблицы прошлых версий
+func (this *migration) Init() error {
+	query := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s ( version TEXT NOT NULL, apply_time INTEGER NOT NULL, PRIMARY KEY(version) );`, this.table),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s ( version TEXT NOT NULL, direction TEXT NOT NULL, apply_nano BIGINT NOT NULL, duration BIGINT NOT NULL, host TEXT NOT NULL, user_name TEXT NOT NULL, error TEXT NOT NULL );`, this.log()),
+	}
+	if err := this.driver.Exec(query...); err != nil {
+		return err
+	}
+	for _, column := range migrationColumns {
+		if _, err := this.driver.Select(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField(column[0], nil)}, nil, nil, nil, nil, db.NewSQLLimit(1), nil); err != nil {
+			if err = this.driver.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, this.table, column[0], column[1])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// хеш таблица и журнал попыток существуют со всеми колонками Init (только чтение, без изменения схемы)
+func (this *migration) Ready() bool {
+	fields := []db.SQLField{db.NewSQLField("version", nil), db.NewSQLField("apply_time", nil)}
+	for _, column := range migrationColumns {
+		fields = append(fields, db.NewSQLField(column[0], nil))
+	}
+	if _, err := this.driver.Select(db.NewSQLTable(this.table), fields, nil, nil, nil, nil, db.NewSQLLimit(1), nil); err != nil {
+		return false
+	}
+	_, err := this.driver.Select(db.NewSQLTable(this.log()), []db.SQLField{db.NewSQLField("version", nil)}, nil, nil, nil, nil, db.NewSQLLimit(1), nil)
+	return err == nil
+}
+
 // текущая версии по времени реализации в бд
 func (this *migration) Version() (string, error) {
 	result := ""
-	if rows, err := this.driver.Select(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("version", nil)}, nil, nil, nil, []db.SQLOrderBy{db.NewSQLOrderBy("apply_time", "DESC"), db.NewSQLOrderBy("version", "DESC")}, db.NewSQLLimit(1), nil); err != nil {
+	if rows, err := this.driver.Select(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("version", nil)}, nil, nil, nil, []db.SQLOrderBy{db.NewSQLOrderBy("apply_time", "DESC"), db.NewSQLOrderBy("apply_nano", "DESC"), db.NewSQLOrderBy("version", "DESC")}, db.NewSQLLimit(1), nil); err != nil {
 		return "", err
 	} else if rows != nil && len(rows) == 1 && rows[0] != nil {
 		if v, ok := rows[0]["version"]; ok && v != nil {
@@ -48,9 +111,9 @@ func (this *migration) Version() (string, error) {
 // список версии по времени реализации в бд (первый (0) является текущей версией)
 func (this *migration) History() ([]string, error) {
 	result := make([]string, 0)
-	if rows, err := this.driver.Select(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("version", nil)}, nil, nil, nil, []db.SQLOrderBy{db.NewSQLOrderBy("apply_time", "DESC"), db.NewSQLOrderBy("version", "DESC")}, nil, nil); err != nil {
+	if rows, err := this.driver.Select(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("version", nil)}, nil, nil, nil, []db.SQLOrderBy{db.NewSQLOrderBy("apply_time", "DESC"), db.NewSQLOrderBy("apply_nano", "DESC"), db.NewSQLOrderBy("version", "DESC")}, nil, nil); err != nil {
 		return make([]string, 0), err
-	} else if rows != nil && len(rows) > 1 {
+	} else if rows != nil && len(rows) > 0 {
 		for _, row := range rows {
 			if v, ok := row["version"]; ok && v != nil {
 				value, ok := v.(string)
@@ -71,7 +134,7 @@ func (this *migration) Exists(version string) (bool, error) {
 	result := false
 	if rows, err := this.driver.Select(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("COUNT(apply_time) as cnt", nil)}, []db.SQLWhere{db.NewSQLWhere("version", version)}, nil, nil, nil, db.NewSQLLimit(1), nil); err != nil {
 		return false, err
-	} else if rows != nil && len(rows) > 1 {
+	} else if rows != nil && len(rows) > 0 {
 		for _, row := range rows {
 			if v, ok := row["cnt"]; ok && v != nil {
 				value, ok := v.(int)
@@ -83,12 +146,167 @@ func (this *migration) Exists(version string) (bool, error) {
 	return result, nil
 }
 
-// добавить версию в хеш таблице
-func (this *migration) Append(version, sql string) error {
-	return this.driver.Exec(sql, fmt.Sprintf("INSERT INTO %s (version, apply_time) VALUES ('%s', %d);", this.table, version, time.Now().UnixNano()/int64(time.Second)))
+// контрольные суммы примененных версий (пустая строка у версий без суммы)
+func (this *migration) Checksums() (map[string]string, error) {
+	records, err := this.Records()
+	if err != nil {
+		return make(map[string]string, 0), err
+	}
+	result := make(map[string]string, len(records))
+	for _, record := range records {
+		result[record.Version] = record.Checksum
+	}
+	return result, nil
+}
+
+// записи хеш таблицы по времени реализации в бд
+func (this *migration) Records() ([]Record, error) {
+	result := make([]Record, 0)
+	fields := []db.SQLField{db.NewSQLField("version", nil), db.NewSQLField("apply_time", nil), db.NewSQLField("apply_nano", nil), db.NewSQLField("duration", nil), db.NewSQLField("host", nil), db.NewSQLField("user_name", nil), db.NewSQLField("checksum", nil)}
+	if rows, err := this.driver.Select(db.NewSQLTable(this.table), fields, nil, nil, nil, []db.SQLOrderBy{db.NewSQLOrderBy("apply_time", "ASC"), db.NewSQLOrderBy("apply_nano", "ASC"), db.NewSQLOrderBy("version", "ASC")}, nil, nil); err != nil {
+		return make([]Record, 0), err
+	} else if rows != nil {
+		for _, row := range rows {
+			record := Record{}
+			if v, ok := row["version"].(string); ok {
+				record.Version = v
+			} else {
+				return make([]Record, 0), fmt.Errorf("wrong field")
+			}
+			record.ApplyTime = getInt64(row["apply_time"])
+			record.ApplyNano = getInt64(row["apply_nano"])
+			record.Duration = getInt64(row["duration"])
+			record.Host = getText(row["host"])
+			record.User = getText(row["user_name"])
+			record.Checksum = getText(row["checksum"])
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+// добавить версию в хеш таблице (start - начало выполнения миграции)
+func (this *migration) Append(version, sql, checksum string, start time.Time) error {
+	host, user := getHostUser()
+	query := fmt.Sprintf("INSERT INTO %s (version, apply_time, apply_nano, duration, host, user_name, checksum) VALUES (%s, %d, %d, %d, %s, %s, %s);", this.table, db.SQLEscape(version), start.Unix(), start.UnixNano(), int64(time.Since(start)), db.SQLEscape(host), db.SQLEscape(user), db.SQLEscape(checksum))
+	if err := this.driver.Exec(sql, query); err != nil {
+		return err
+	}
+	// миграция уже применена: длительность sql уточняется отдельно, ошибка этой записи не является ошибкой миграции
+	if len(sql) > 0 {
+		_ = this.driver.Update(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("duration", int64(time.Since(start)))}, []db.SQLWhere{db.NewSQLWhere("version", version)})
+	}
+	return nil
+}
+
+// обновить контрольную сумму версии
+func (this *migration) Repair(version, checksum string) error {
+	return this.driver.Update(db.NewSQLTable(this.table), []db.SQLField{db.NewSQLField("checksum", checksum)}, []db.SQLWhere{db.NewSQLWhere("version", version)})
 }
 
 // удалить версию в хеш таблице
 func (this *migration) Remove(version, sql string) error {
 	return this.driver.Exec(sql, fmt.Sprintf("DELETE FROM %s WHERE version = '%s';", this.table, version))
 }
+
+// записать попытку выполнения миграции в журнал
+func (this *migration) Attempt(version, direction string, start time.Time, err error) error {
+	host, user := getHostUser()
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	query := fmt.Sprintf("INSERT INTO %s (version, direction, apply_nano, duration, host, user_name, error) VALUES (%s, %s, %d, %d, %s, %s, %s);", this.log(), db.SQLEscape(version), db.SQLEscape(direction), start.UnixNano(), int64(time.Since(start)), db.SQLEscape(host), db.SQLEscape(user), db.SQLEscape(message))
+	return this.driver.Exec(query)
+}
+
+// журнал попыток выполнения по времени (все версии, если version пустая)
+func (this *migration) Attempts(version string) ([]Attempt, error) {
+	result := make([]Attempt, 0)
+	var where []db.SQLWhere = nil
+	if len(version) > 0 {
+		where = []db.SQLWhere{db.NewSQLWhere("version", version)}
+	}
+	fields := []db.SQLField{db.NewSQLField("version", nil), db.NewSQLField("direction", nil), db.NewSQLField("apply_nano", nil), db.NewSQLField("duration", nil), db.NewSQLField("host", nil), db.NewSQLField("user_name", nil), db.NewSQLField("error", nil)}
+	if rows, err := this.driver.Select(db.NewSQLTable(this.log()), fields, where, nil, nil, []db.SQLOrderBy{db.NewSQLOrderBy("apply_nano", "ASC")}, nil, nil); err != nil {
+		return make([]Attempt, 0), err
+	} else if rows != nil {
+		for _, row := range rows {
+			attempt := Attempt{}
+			attempt.Version = getText(row["version"])
+			attempt.Direction = getText(row["direction"])
+			attempt.ApplyNano = getInt64(row["apply_nano"])
+			attempt.Duration = getInt64(row["duration"])
+			attempt.Host = getText(row["host"])
+			attempt.User = getText(row["user_name"])
+			attempt.Error = getText(row["error"])
+			result = append(result, attempt)
+		}
+	}
+	return result, nil
+}
+
+func (this *migration) log() string {
+	return this.table + "_log"
+}
+
+/***********************************************************************************************************************
+ * helper
+ */
+
+// колонки хеш таблицы, добавленные после первой версии
+var migrationColumns = [][]string{
+	{"checksum", "TEXT NOT NULL DEFAULT ''"},
+	{"apply_nano", "BIGINT NOT NULL DEFAULT 0"},
+	{"duration", "BIGINT NOT NULL DEFAULT 0"},
+	{"host", "TEXT NOT NULL DEFAULT ''"},
+	{"user_name", "TEXT NOT NULL DEFAULT ''"},
+}
+
+var hostUser struct {
+	sync.Once
+	host string
+	user string
+}
+
+func getHostUser() (string, string) {
+	hostUser.Do(func() {
+		hostUser.host, _ = os.Hostname()
+		if u, err := user.Current(); err == nil && u != nil {
+			hostUser.user = u.Username
+		} else {
+			hostUser.user = os.Getenv("USER")
+		}
+	})
+	return hostUser.host, hostUser.user
+}
+
+func getInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case []byte:
+		result, _ := strconv.ParseInt(string(v), 10, 64)
+		return result
+	case string:
+		result, _ := strconv.ParseInt(v, 10, 64)
+		return result
+	}
+	return 0
+}
+
+func getText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
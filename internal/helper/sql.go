@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"strings"
+)
+
+// PostgreSQL array literal: {a,"b c",NULL} (nil item is NULL)
+func ParseSQLArray(value string) []*string {
+	result := make([]*string, 0)
+	value = strings.Trim(value, " \t\n\r")
+	if len(value) < 2 || value[0] != '{' || value[len(value)-1] != '}' {
+		return result
+	}
+	value = value[1 : len(value)-1]
+	if len(value) == 0 {
+		return result
+	}
+	item := strings.Builder{}
+	quoted := false
+	escaped := false
+	wasQuoted := false
+	push := func() {
+		s := item.String()
+		if !wasQuoted && strings.ToUpper(strings.TrimSpace(s)) == "NULL" {
+			result = append(result, nil)
+		} else {
+			if !wasQuoted {
+				s = strings.TrimSpace(s)
+			}
+			result = append(result, &s)
+		}
+		item.Reset()
+		wasQuoted = false
+	}
+	for _, c := range value {
+		switch {
+		case escaped:
+			item.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case c == ',' && !quoted:
+			push()
+		default:
+			item.WriteRune(c)
+		}
+	}
+	push()
+	return result
+}
@@ -11,5 +11,6 @@ const (
 	MethodConnect = 0x00800000
 	MethodTrace   = 0x01000000
 
-	WithID = 0x00000001
+	WithID    = 0x00000001
+	WithCache = 0x00000002
 )
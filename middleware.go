@@ -0,0 +1,25 @@
+package grest
+
+type HandlerFunc func(r *Request) (int, map[string]string, interface{}, error)
+
+// calls next to continue the chain, or answers itself to short-circuit; may change the request, headers or body,
+// r.User set before next replaces AccessControl.User, roles are checked anyway
+type Middleware func(r *Request, next HandlerFunc) (int, map[string]string, interface{}, error)
+
+/***********************************************************************************************************************
+ * helper
+ */
+
+// handler wrapped by the middleware, first is the outermost
+func getMiddlewareHandler(handler HandlerFunc, middleware []Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if middleware[i] == nil {
+			continue
+		}
+		m, next := middleware[i], handler
+		handler = func(r *Request) (int, map[string]string, interface{}, error) {
+			return m(r, next)
+		}
+	}
+	return handler
+}
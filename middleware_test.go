@@ -0,0 +1,103 @@
+package grest
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"github.com/prorochestvo/grest/usr"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	trace := make([]string, 0)
+	traced := func(name string) Middleware {
+		return func(r *Request, next HandlerFunc) (int, map[string]string, interface{}, error) {
+			trace = append(trace, name)
+			code, head, body, err := next(r)
+			trace = append(trace, "/"+name)
+			return code, head, body, err
+		}
+	}
+	action := &testAction{}
+	action.handler = func(r *Request) (int, map[string]string, interface{}, error) {
+		trace = append(trace, "handler")
+		return http.StatusOK, nil, map[string]interface{}{"user": r.User.ID()}, nil
+	}
+	controller := &testMiddlewareController{testController{path: "users", model: newTestModel(), actions: []Action{NewActionWithMiddleware(action, traced("action"))}}, []Middleware{traced("controller")}}
+	router := newTestRouter(newTestDriver(db.DialectPostgreSQL), controller)
+	users := 0
+	router.AccessControl.User = func(_ *Request) (usr.User, error) {
+		users++
+		return usr.DefaultUser, nil
+	}
+	router.Use(traced("router1"), traced("router2"))
+	serve := func() *httptest.ResponseRecorder {
+		trace = trace[:0]
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/login/demo", nil))
+		return w
+	}
+	// ordering: router -> controller -> action -> handle
+	if w := serve(); w.Code != http.StatusOK {
+		t.Fatalf("middleware[order]: wrong status %d, body %s", w.Code, w.Body.String())
+	} else if s := strings.Join(trace, ","); s != "router1,router2,controller,action,handler,/action,/controller,/router2,/router1" {
+		t.Errorf("middleware[order]: wrong order %s", s)
+	} else if users != 1 {
+		t.Errorf("middleware[order]: AccessControl.User called %d times", users)
+	}
+	// header changes and body replacement
+	router.Use(func(r *Request, next HandlerFunc) (int, map[string]string, interface{}, error) {
+		code, head, _, err := next(r)
+		if head == nil {
+			head = make(map[string]string, 0)
+		}
+		head["X-Total"] = "1"
+		return code, head, map[string]string{"replaced": "yes"}, err
+	})
+	if w := serve(); w.Header().Get("X-Total") != "1" || w.Body.String() != `{"replaced":"yes"}` {
+		t.Errorf("middleware[header]: wrong header «%s» or body %s", w.Header().Get("X-Total"), w.Body.String())
+	}
+	// user set by middleware, roles are checked anyway
+	users = 0
+	action.roles = usr.Roles{2}
+	role := usr.Role(2)
+	controller.middleware = append(controller.middleware, func(r *Request, next HandlerFunc) (int, map[string]string, interface{}, error) {
+		r.User = usr.NewUser(int64(7), role)
+		return next(r)
+	})
+	router.middleware = router.middleware[:2]
+	if w := serve(); w.Code != http.StatusOK || w.Body.String() != `{"user":7}` {
+		t.Errorf("middleware[user]: wrong status %d, body %s", w.Code, w.Body.String())
+	} else if users != 0 {
+		t.Errorf("middleware[user]: AccessControl.User called %d times", users)
+	}
+	role = usr.Role(3)
+	if w := serve(); w.Code != http.StatusForbidden {
+		t.Errorf("middleware[user]: wrong status %d for the role %d", w.Code, role)
+	}
+	// short-circuit
+	users = 0
+	router.middleware = append([]Middleware{func(r *Request, next HandlerFunc) (int, map[string]string, interface{}, error) {
+		return http.StatusTeapot, map[string]string{"X-Stop": "1"}, nil, fmt.Errorf("stopped")
+	}}, router.middleware...)
+	if w := serve(); w.Code != http.StatusTeapot || w.Header().Get("X-Stop") != "1" || w.Body.String() != `{"error":"stopped"}` {
+		t.Errorf("middleware[short-circuit]: wrong status %d, body %s", w.Code, w.Body.String())
+	} else if len(trace) != 0 || users != 0 {
+		t.Errorf("middleware[short-circuit]: chain continued %v", trace)
+	}
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+type testMiddlewareController struct {
+	testController
+	middleware []Middleware
+}
+
+func (this *testMiddlewareController) Middleware() []Middleware {
+	return this.middleware
+}
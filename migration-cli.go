@@ -0,0 +1,199 @@
+package grest
+
+import (
+	"flag"
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MigrationExitOK    = 0
+	MigrationExitError = 1
+	MigrationExitUsage = 2
+)
+
+/*
+ * migration command line for main (args without the program name), returns the exit code
+ *
+ *   up                     apply all pending migrations
+ *   down [N]               rollback N (1 by default) migration steps
+ *   goto VERSION           apply or rollback migrations until VERSION
+ *   status                 applied and pending migrations
+ *   reset                  rollback all migration steps
+ *   revise                 differences between models and database, exit code 1 if any
+ *   create NAME            create NNNN_NAME.up.sql / NNNN_NAME.down.sql files in -dir
+ *   seed [TAG...]          upsert seeds without tags and seeds with the given tags (see Router.Seed)
+ *
+ *   -dry-run               print the SQL plan instead of executing (not supported by seed)
+ *   -dir PATH              directory of migration files (migrations by default), appended to Router.Migration
+ *                          before running, versions already appended are kept, a missing default directory is skipped
+ */
+func MigrationCLI(router *Router, args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(router.Stderr)
+	dryRun := flags.Bool("dry-run", false, "print the SQL plan instead of executing (not supported by seed)")
+	dir := flags.String("dir", "migrations", "directory of migration files")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(router.Stderr, "usage: migrate [-dry-run] [-dir PATH] up | down [N] | goto VERSION | status | reset | revise | create NAME | seed [TAG...]\n")
+		flags.PrintDefaults()
+	}
+	// flags before and after the command (migrate up -dry-run)
+	params := make([]string, 0)
+	for rest := args; ; rest = rest[1:] {
+		if err := flags.Parse(rest); err != nil {
+			return MigrationExitUsage
+		} else if rest = flags.Args(); len(rest) == 0 {
+			break
+		}
+		params = append(params, rest[0])
+	}
+	command := ""
+	if len(params) > 0 {
+		command, params = params[0], params[1:]
+	}
+	// seeds are upserted without a plan
+	if command == "seed" && *dryRun {
+		_, _ = fmt.Fprintf(router.Stderr, "-dry-run is not supported by seed\n")
+		return MigrationExitUsage
+	}
+	dry := router.Migration.DryRun
+	router.Migration.DryRun = *dryRun || dry
+	defer func() { router.Migration.DryRun = dry }()
+	sources := router.Migration.sources
+	defer func() { router.Migration.sources = sources }()
+	explicit := false
+	flags.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "dir" })
+	var err error = nil
+	if command != "create" && command != "seed" {
+		if err = appendMigrationFiles(router.Migration, *dir, explicit); err != nil {
+			_, _ = fmt.Fprintf(router.Stderr, "%s\n", err.Error())
+			return MigrationExitError
+		}
+	}
+	switch {
+	case command == "up" && len(params) == 0:
+		err = router.Migration.Up()
+	case command == "down" && len(params) <= 1:
+		n := 1
+		if len(params) == 1 {
+			if n, err = strconv.Atoi(params[0]); err != nil || n < 1 {
+				flags.Usage()
+				return MigrationExitUsage
+			}
+		}
+		err = router.Migration.DownN(n)
+	case command == "goto" && len(params) == 1:
+		err = router.Migration.Goto(params[0])
+	case command == "reset" && len(params) == 0:
+		err = router.Migration.Reset()
+	case command == "status" && len(params) == 0:
+		var status []MigrationStatus
+		if status, err = router.Migration.Status(); err == nil {
+			for _, s := range status {
+				switch {
+				case s.Orphan:
+					_, _ = fmt.Fprintf(router.Stdout, "? %s\tapplied, source not found\n", s.Version)
+				case s.Changed:
+					_, _ = fmt.Fprintf(router.Stdout, "⚠ %s\tapplied, changed after being applied\n", s.Version)
+				case s.Applied:
+					_, _ = fmt.Fprintf(router.Stdout, "✔ %s\tapplied\n", s.Version)
+				default:
+					_, _ = fmt.Fprintf(router.Stdout, "✘ %s\tpending\n", s.Version)
+				}
+			}
+		}
+	case command == "revise" && len(params) == 0:
+		if err = router.Migration.Revise(); err != nil {
+			if _, ok := err.(*ReviseReport); ok {
+				_, _ = fmt.Fprintf(router.Stdout, "%s\n", err.Error())
+				return MigrationExitError
+			}
+		}
+	case command == "create" && len(params) == 1:
+		var files []string
+		if files, err = createMigrationFiles(*dir, params[0]); err == nil {
+			for _, file := range files {
+				_, _ = fmt.Fprintf(router.Stdout, "✔ %s\n", file)
+			}
+		}
+	case command == "seed":
+		err = router.Seed.Run(params...)
+	default:
+		flags.Usage()
+		return MigrationExitUsage
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(router.Stderr, "%s\n", err.Error())
+		return MigrationExitError
+	}
+	return MigrationExitOK
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+var migrationCLIName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// migration files of the directory which are not appended yet (e.g. by main with the same directory)
+func appendMigrationFiles(m *migration, dir string, explicit bool) error {
+	if info, err := os.Stat(dir); err != nil && os.IsNotExist(err) && !explicit {
+		return nil
+	} else if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	migrations, err := db.NewMigrationsFromDir(dir)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(m.sources))
+	for _, source := range m.sources {
+		exists[source.Version()] = true
+	}
+	for _, migration := range migrations {
+		if !exists[migration.Version()] {
+			m.Append(migration)
+		}
+	}
+	return nil
+}
+
+// next NNNN_name.up.sql / NNNN_name.down.sql pair (see db.NewMigrationsFromDir)
+func createMigrationFiles(dir, name string) ([]string, error) {
+	name = strings.Trim(migrationCLIName.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if len(name) == 0 {
+		return nil, fmt.Errorf("empty migration name")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	next := 1
+	for _, file := range files {
+		if pos := strings.Index(file.Name(), "_"); pos > 0 {
+			if n, err := strconv.Atoi(file.Name()[:pos]); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+	}
+	result := make([]string, 0, 2)
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", next, name, direction))
+		if err = ioutil.WriteFile(path, []byte(fmt.Sprintf("-- %s: %s\n", name, direction)), 0644); err != nil {
+			return nil, err
+		}
+		result = append(result, path)
+	}
+	return result, nil
+}
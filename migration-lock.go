@@ -0,0 +1,73 @@
+package grest
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"github.com/prorochestvo/grest/internal/dbase"
+	"os"
+	"time"
+)
+
+/*
+ * hold the migration lock for the whole run: driver lock (see db.DriverWithLock) or a lock row with expiry,
+ * taken before init, so only the lock table is created by concurrent instances; read paths never init (see reader)
+ */
+func (this *migration) lock() (func(), error) {
+	if this.dry() {
+		return func() {}, nil
+	}
+	if d, ok := this.driver.(db.DriverWithLock); ok && d != nil {
+		unlock, err := d.Lock(this.Table, this.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			if err := unlock(); err != nil && this.router.Stderr != nil {
+				_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			}
+		}, nil
+	}
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+	expiry := this.LockExpiry
+	if expiry < time.Second {
+		expiry = time.Second
+	}
+	table := dbase.NewLock(this.driver, this.lockTable())
+	deadline := time.Now().Add(this.LockTimeout)
+	for {
+		if ok, err := table.Acquire(this.Table, owner, expiry); err != nil {
+			return nil, err
+		} else if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("migration lock timeout: \"%s\" is locked by another instance", this.Table)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	// keep the lock while migrations run longer than expiry
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(expiry / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = table.Refresh(this.Table, owner, expiry)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		if err := table.Release(this.Table, owner); err != nil && this.router.Stderr != nil {
+			_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		}
+	}, nil
+}
+
+func (this *migration) lockTable() string {
+	return this.Table + "_lock"
+}
@@ -0,0 +1,146 @@
+package grest
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"github.com/prorochestvo/grest/internal/helper"
+	"sort"
+	"strings"
+)
+
+/*
+ * migrations in dependency order: each migration follows the previous version of its namespace (controller path)
+ * and its dependencies (see db.MigrationAfter), ties are broken by version
+ */
+func (this *migration) migrations() ([]db.Migration, error) {
+	nodes := make([]*migrationNode, 0, len(this.sources))
+	for _, m := range this.sources {
+		nodes = append(nodes, &migrationNode{Migration: m})
+	}
+	for _, controller := range this.router.controllers {
+		if controller == nil {
+			continue
+		}
+		if c, ok := controller.(db.MigrationController); ok == true && c != nil {
+			namespace := helper.HttpPathTrim(controller.Path())
+			if n, ok := controller.(db.MigrationControllerWithNamespace); ok && n != nil {
+				namespace = n.Namespace()
+			}
+			for _, m := range c.Migrations() {
+				nodes = append(nodes, &migrationNode{namespace: namespace, Migration: m})
+			}
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Version() < nodes[j].Version() })
+	// versions are the history keys
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i].Version() == nodes[i-1].Version() {
+			return nil, fmt.Errorf("duplicate migration version \"%s\" (%s, %s)", nodes[i].Version(), nodes[i-1].name(), nodes[i].name())
+		}
+	}
+	// edges: previous version of the namespace and dependencies
+	for i, node := range nodes {
+		for j := i - 1; j >= 0; j-- {
+			if nodes[j].namespace == node.namespace {
+				node.after = append(node.after, nodes[j])
+				break
+			}
+		}
+		if m, ok := node.Migration.(db.MigrationWithDependencies); ok && m != nil {
+			for _, dependency := range m.Dependencies() {
+				namespace, version := dependency, ""
+				if pos := strings.LastIndex(dependency, "@"); pos >= 0 {
+					namespace, version = dependency[:pos], dependency[pos+1:]
+				}
+				found := false
+				for _, n := range nodes {
+					if n != node && n.namespace == namespace && (len(version) == 0 || n.Version() == version) {
+						node.after = append(node.after, n)
+						found = true
+					}
+				}
+				if !found {
+					return nil, fmt.Errorf("migration \"%s\" depends on unknown \"%s\"", node.name(), dependency)
+				}
+			}
+		}
+	}
+	// topological order, the smallest version first among ready migrations
+	result := make([]db.Migration, 0, len(nodes))
+	done := make(map[*migrationNode]bool, len(nodes))
+	for len(result) < len(nodes) {
+		var next *migrationNode = nil
+		for _, node := range nodes {
+			if done[node] || !node.ready(done) {
+				continue
+			}
+			next = node
+			break
+		}
+		if next == nil {
+			return nil, fmt.Errorf("migration dependency cycle: %s", strings.Join(getMigrationCycle(nodes, done), " -> "))
+		}
+		done[next] = true
+		result = append(result, next.Migration)
+	}
+	return result, nil
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+type migrationNode struct {
+	namespace string
+	after     []*migrationNode
+	db.Migration
+}
+
+func (this *migrationNode) ready(done map[*migrationNode]bool) bool {
+	for _, node := range this.after {
+		if !done[node] {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *migrationNode) name() string {
+	if len(this.namespace) == 0 {
+		return this.Version()
+	}
+	return this.namespace + "@" + this.Version()
+}
+
+// names of a dependency cycle among unordered nodes: each of them waits for another unordered one, so the walk loops
+func getMigrationCycle(nodes []*migrationNode, done map[*migrationNode]bool) []string {
+	var node *migrationNode = nil
+	for _, n := range nodes {
+		if !done[n] {
+			node = n
+			break
+		}
+	}
+	path := make([]*migrationNode, 0)
+	position := make(map[*migrationNode]int, 0)
+	for node != nil {
+		if i, ok := position[node]; ok {
+			result := make([]string, 0, len(path)-i+1)
+			for _, n := range path[i:] {
+				result = append(result, n.name())
+			}
+			return append(result, node.name())
+		}
+		position[node] = len(path)
+		path = append(path, node)
+		var next *migrationNode = nil
+		for _, n := range node.after {
+			if !done[n] {
+				next = n
+				break
+			}
+		}
+		node = next
+	}
+	return make([]string, 0)
+}
@@ -0,0 +1,142 @@
+package grest
+
+import (
+	"github.com/prorochestvo/grest/internal/dbase"
+	"time"
+)
+
+type MigrationStep struct {
+	Version   string `json:"version"`
+	Direction string `json:"direction"` // up or down
+	SQL       string `json:"sql"`
+}
+
+/* SQL plan of Up in order, without touching the database apart from reading history */
+func (this *migration) PlanUp() ([]MigrationStep, error) {
+	return this.planned(this.Up)
+}
+
+/* SQL plan of DownN */
+func (this *migration) PlanDown(n int) ([]MigrationStep, error) {
+	return this.planned(func() error { return this.DownN(n) })
+}
+
+/* SQL plan of Goto */
+func (this *migration) PlanGoto(version string) ([]MigrationStep, error) {
+	return this.planned(func() error { return this.Goto(version) })
+}
+
+/* SQL plan of Reset */
+func (this *migration) PlanReset() ([]MigrationStep, error) {
+	return this.planned(this.Reset)
+}
+
+func (this *migration) planned(run func() error) ([]MigrationStep, error) {
+	result := make([]MigrationStep, 0)
+	this.plan = &result
+	defer func() { this.plan = nil }()
+	if err := run(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (this *migration) dry() bool {
+	return this.DryRun || this.plan != nil
+}
+
+/* history table, records steps instead of executing in dry run */
+func (this *migration) history() dbase.Migration {
+	if !this.dry() {
+		return dbase.NewMigration(this.driver, this.Table)
+	}
+	return this.reader()
+}
+
+/* history table for read-only callers without the lock: never created or altered, nothing applied until Init */
+func (this *migration) reader() dbase.Migration {
+	result := migrationPlan{}
+	result.Migration = dbase.NewMigration(this.driver, this.Table)
+	result.steps = this.plan
+	// history tables or their columns do not exist yet
+	result.missing = !result.Migration.Ready()
+	return &result
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+type migrationPlan struct {
+	dbase.Migration
+	steps   *[]MigrationStep
+	missing bool
+}
+
+func (this *migrationPlan) Version() (string, error) {
+	if this.missing {
+		return "", nil
+	}
+	return this.Migration.Version()
+}
+
+func (this *migrationPlan) History() ([]string, error) {
+	if this.missing {
+		return make([]string, 0), nil
+	}
+	return this.Migration.History()
+}
+
+func (this *migrationPlan) Checksums() (map[string]string, error) {
+	if this.missing {
+		return make(map[string]string, 0), nil
+	}
+	return this.Migration.Checksums()
+}
+
+func (this *migrationPlan) Records() ([]dbase.Record, error) {
+	if this.missing {
+		return make([]dbase.Record, 0), nil
+	}
+	return this.Migration.Records()
+}
+
+func (this *migrationPlan) Exists(version string) (bool, error) {
+	if this.missing {
+		return false, nil
+	}
+	return this.Migration.Exists(version)
+}
+
+func (this *migrationPlan) Init() error {
+	return nil
+}
+
+func (this *migrationPlan) Attempt(version, direction string, start time.Time, err error) error {
+	return nil
+}
+
+func (this *migrationPlan) Attempts(version string) ([]dbase.Attempt, error) {
+	if this.missing {
+		return make([]dbase.Attempt, 0), nil
+	}
+	return this.Migration.Attempts(version)
+}
+
+func (this *migrationPlan) Append(version, sql, checksum string, start time.Time) error {
+	if this.steps != nil {
+		*this.steps = append(*this.steps, MigrationStep{Version: version, Direction: "up", SQL: sql})
+	}
+	return nil
+}
+
+func (this *migrationPlan) Repair(version, checksum string) error {
+	return nil
+}
+
+func (this *migrationPlan) Remove(version, sql string) error {
+	if this.steps != nil {
+		*this.steps = append(*this.steps, MigrationStep{Version: version, Direction: "down", SQL: sql})
+	}
+	return nil
+}
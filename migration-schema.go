@@ -0,0 +1,279 @@
+package grest
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"strings"
+)
+
+/* CREATE TABLE of all model tables in one migration */
+func (this *migration) Schema(version string) db.Migration {
+	dialect := db.GetDialect(this.driver)
+	tables := this.tables()
+	up := make([]string, 0, len(tables))
+	down := make([]string, 0, len(tables))
+	for i, table := range tables {
+		up = append(up, table.create(this.driver, dialect))
+		down = append(down, tables[len(tables)-i-1].drop(this.driver))
+	}
+	return db.NewMigration(version, strings.Join(up, "\n"), strings.Join(down, "\n"))
+}
+
+/* migrations which bring the live schema to the models: new tables, added, removed and changed columns (proposals, see Fix) */
+func (this *migration) Diff() ([]db.Migration, error) {
+	changes, err := this.diff(true)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]db.Migration, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, change.Migration)
+	}
+	return result, nil
+}
+
+/* apply the diff without history, removed columns are dropped only with drop = true */
+func (this *migration) Fix(drop bool) error {
+	unlock, err := this.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	changes, err := this.diff(drop)
+	if err != nil {
+		return err
+	}
+	result := make([]string, 0)
+	for _, change := range changes {
+		version := change.Version()
+		if this.dry() {
+			if this.plan != nil {
+				*this.plan = append(*this.plan, MigrationStep{Version: version, Direction: "up", SQL: change.Up()})
+			}
+			if this.router.Stdout != nil {
+				_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\n%s\n", version, change.Up())))
+			}
+			continue
+		}
+		if err = this.driver.Exec(change.Up()); err != nil {
+			if this.router.Stderr != nil {
+				_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n%s\n", change.Up(), err.Error())))
+			}
+			if this.router.Stdout != nil {
+				_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("⚠ %s\n\t%s\n", version, strings.ReplaceAll(err.Error(), "\n", " "))))
+			}
+			result = append(result, err.Error())
+		} else if this.router.Stdout != nil {
+			_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\n", version)))
+		}
+	}
+	if len(result) > 0 {
+		return fmt.Errorf(strings.Join(result, "\n"))
+	}
+	return nil
+}
+
+// columns unknown to the model are included only with drop = true
+func (this *migration) diff(drop bool) ([]schemaChange, error) {
+	dialect := db.GetDialect(this.driver)
+	schema := db.GetSchema(this.driver)
+	exists, err := schema.Tables()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]schemaChange, 0)
+	for _, table := range this.tables() {
+		if !schemaHasTable(exists, table.name) {
+			result = append(result, newSchemaChange("create", fmt.Sprintf("%s.create", table.name), table.create(this.driver, dialect), table.drop(this.driver)))
+			continue
+		}
+		columns, err := schema.Columns(table.name)
+		if err != nil {
+			return nil, err
+		}
+		name := this.driver.Escape(table.name)
+		for _, column := range table.columns {
+			var live db.SQLColumn = nil
+			for _, c := range columns {
+				if c.Name() == column.name {
+					live = c
+					break
+				}
+			}
+			escaped := this.driver.Escape(column.name)
+			kind := db.SQLType(dialect, column.kind)
+			if live == nil {
+				// new columns are nullable, NOT NULL requires a default value for existing rows
+				up := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", name, escaped, kind)
+				down := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", name, escaped)
+				result = append(result, newSchemaChange("add", fmt.Sprintf("%s.add.%s", table.name, column.name), up, down))
+				continue
+			}
+			// SQLite can not alter columns
+			if dialect == db.DialectSQLite || column.primary {
+				continue
+			}
+			// NOT NULL is changed only when the field asks for it (required or nullable)
+			wrongType := !db.SQLTypeCompatible(dialect, kind, live.Type())
+			setNotNull := column.required && !column.nullable && live.Nullable()
+			dropNotNull := column.nullable && !live.Nullable()
+			if !wrongType && !setNotNull && !dropNotNull {
+				continue
+			}
+			up := make([]string, 0)
+			down := make([]string, 0)
+			if dialect == db.DialectMySQL {
+				up = append(up, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s%s;", name, escaped, kind, schemaNotNull((live.Nullable() || dropNotNull) && !setNotNull)))
+				down = append(down, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s%s;", name, escaped, live.Type(), schemaNotNull(live.Nullable())))
+			} else {
+				if wrongType {
+					up = append(up, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;", name, escaped, kind, escaped, kind))
+					down = append(down, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;", name, escaped, live.Type(), escaped, live.Type()))
+				}
+				if dropNotNull {
+					up = append(up, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;", name, escaped))
+					down = append(down, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", name, escaped))
+				} else if setNotNull {
+					up = append(up, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", name, escaped))
+					down = append(down, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;", name, escaped))
+				}
+			}
+			result = append(result, newSchemaChange("alter", fmt.Sprintf("%s.alter.%s", table.name, column.name), strings.Join(up, "\n"), strings.Join(down, "\n")))
+		}
+		if drop {
+			for _, live := range columns {
+				known := false
+				for _, column := range table.columns {
+					if column.name == live.Name() {
+						known = true
+						break
+					}
+				}
+				if known {
+					continue
+				}
+				escaped := this.driver.Escape(live.Name())
+				up := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", name, escaped)
+				down := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", name, escaped, live.Type())
+				result = append(result, newSchemaChange("drop", fmt.Sprintf("%s.drop.%s", table.name, live.Name()), up, down))
+			}
+		}
+	}
+	return result, nil
+}
+
+/* tables of the registered models, each table once */
+func (this *migration) tables() []schemaTable {
+	result := make([]schemaTable, 0)
+	for _, controller := range this.router.controllers {
+		if controller == nil {
+			continue
+		}
+		model := getControllerModel(controller)
+		if model == nil || len(model.Table()) == 0 {
+			continue
+		}
+		exists := false
+		for _, t := range result {
+			if t.name == model.Table() {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+		result = append(result, newSchemaTable(model, getControllerIDName(controller)))
+	}
+	return result
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+type schemaChange struct {
+	kind string
+	db.Migration
+}
+
+func newSchemaChange(kind, version, up, down string) schemaChange {
+	return schemaChange{kind: kind, Migration: db.NewMigration(version, up, down)}
+}
+
+type schemaTable struct {
+	name    string
+	columns []schemaColumn
+}
+
+type schemaColumn struct {
+	name     string
+	kind     string
+	required bool
+	nullable bool
+	primary  bool
+}
+
+func newSchemaTable(model Model, id string) schemaTable {
+	result := schemaTable{}
+	result.name = model.Table()
+	result.columns = make([]schemaColumn, 0)
+	for _, field := range model.Fields() {
+		if len(getFieldExpression(field)) > 0 {
+			continue
+		}
+		column := schemaColumn{}
+		column.name = getFieldColumn(field)
+		column.kind = getFieldType(field)
+		column.required = getFieldRequired(field)
+		column.nullable = getFieldNullable(field)
+		column.primary = field.Name() == id
+		result.columns = append(result.columns, column)
+	}
+	return result
+}
+
+func (this schemaTable) create(driver db.Driver, dialect string) string {
+	columns := make([]string, 0, len(this.columns))
+	for _, column := range this.columns {
+		kind := db.SQLType(dialect, column.kind)
+		escaped := driver.Escape(column.name)
+		if column.primary {
+			integer := strings.HasPrefix(column.kind, "int") || strings.HasPrefix(column.kind, "uint")
+			switch {
+			case integer && dialect == db.DialectSQLite:
+				columns = append(columns, fmt.Sprintf("%s INTEGER PRIMARY KEY AUTOINCREMENT", escaped))
+			case integer && dialect == db.DialectMySQL:
+				columns = append(columns, fmt.Sprintf("%s %s AUTO_INCREMENT PRIMARY KEY", escaped, kind))
+			case integer:
+				columns = append(columns, fmt.Sprintf("%s BIGSERIAL PRIMARY KEY", escaped))
+			default:
+				columns = append(columns, fmt.Sprintf("%s %s PRIMARY KEY", escaped, kind))
+			}
+			continue
+		}
+		// nullable unless the field is required
+		columns = append(columns, fmt.Sprintf("%s %s%s", escaped, kind, schemaNotNull(column.nullable || !column.required)))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n);", driver.Escape(this.name), strings.Join(columns, ",\n  "))
+}
+
+func (this schemaTable) drop(driver db.Driver) string {
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s;", driver.Escape(this.name))
+}
+
+func schemaNotNull(nullable bool) string {
+	if nullable {
+		return " NULL"
+	}
+	return " NOT NULL"
+}
+
+func schemaHasTable(tables []string, name string) bool {
+	for _, table := range tables {
+		if strings.EqualFold(table, name) {
+			return true
+		}
+	}
+	return false
+}
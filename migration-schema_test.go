@@ -0,0 +1,366 @@
+package grest
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrationSchema(t *testing.T) {
+	router := newTestRouter(newTestDriver(db.DialectPostgreSQL), &testController{path: "users", model: newTestModel()})
+	up := router.Migration.Schema("0001").Up()
+	for _, expected := range []string{`"id" BIGSERIAL PRIMARY KEY`, `"login" TEXT NOT NULL`, `"name" TEXT NULL`} {
+		if !strings.Contains(up, expected) {
+			t.Errorf("migration[schema]: «%s» not found in\n%s", expected, up)
+		}
+	}
+	router = newTestRouter(newTestDriver(db.DialectSQLite), &testController{path: "users", id: "uid", model: newTestModel(INT64("uid"))})
+	if up = router.Migration.Schema("0001").Up(); !strings.Contains(up, `"uid" INTEGER PRIMARY KEY AUTOINCREMENT`) {
+		t.Errorf("migration[schema]: primary key not found in\n%s", up)
+	} else if strings.Contains(up, `"id" INTEGER PRIMARY KEY`) {
+		t.Errorf("migration[schema]: wrong primary key in\n%s", up)
+	}
+}
+
+func TestMigrationDiff(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	driver.tables["users"] = []db.SQLColumn{
+		db.NewSQLColumn("id", "bigint", false, true),
+		db.NewSQLColumn("login", "text", true, false),
+		db.NewSQLColumn("name", "int4[]", false, false),
+		db.NewSQLColumn("legacy", "text", true, false),
+	}
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	changes, err := router.Migration.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := make([]string, 0, len(changes))
+	for _, change := range changes {
+		versions = append(versions, change.Version())
+	}
+	// removed columns are proposed
+	if strings.Join(versions, ",") != "users.alter.login,users.alter.name,users.drop.legacy" {
+		t.Fatalf("migration[diff]: wrong changes %v", versions)
+	} else if up := changes[2].Up(); up != `ALTER TABLE "users" DROP COLUMN "legacy";` {
+		t.Errorf("migration[diff]: wrong drop\n%s", up)
+	}
+	report, err := router.Migration.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := false
+	for _, issue := range report.Issues {
+		unknown = unknown || (issue.Code == "unknown" && issue.Table == "users" && issue.Column == "legacy")
+	}
+	if !unknown || len(report.Fix) != 3 {
+		t.Errorf("migration[report]: unknown column is not reported %v, fix %d", report.Issues, len(report.Fix))
+	}
+	if up := changes[0].Up(); up != `ALTER TABLE "users" ALTER COLUMN "login" SET NOT NULL;` {
+		t.Errorf("migration[diff]: wrong up\n%s", up)
+	}
+	// not required column keeps NOT NULL, the type is restored by the formatted type
+	if up := changes[1].Up(); strings.Contains(up, "NOT NULL") {
+		t.Errorf("migration[diff]: unexpected NOT NULL change\n%s", up)
+	} else if down := changes[1].Down(); down != `ALTER TABLE "users" ALTER COLUMN "name" TYPE int4[] USING "name"::int4[];` {
+		t.Errorf("migration[diff]: wrong down\n%s", down)
+	}
+	if err = router.Migration.Fix(false); err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(strings.Join(driver.executed, "\n"), "DROP COLUMN") {
+		t.Errorf("migration[fix]: unexpected drop\n%s", strings.Join(driver.executed, "\n"))
+	}
+	driver.executed = nil
+	if err = router.Migration.Fix(true); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(strings.Join(driver.executed, "\n"), `ALTER TABLE "users" DROP COLUMN "legacy";`) {
+		t.Errorf("migration[fix]: drop not found\n%s", strings.Join(driver.executed, "\n"))
+	}
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+func newTestRouter(driver db.Driver, controllers ...Controller) *Router {
+	result := NewJSONRouter(driver)
+	result.Stdout = ioutil.Discard
+	result.Stderr = ioutil.Discard
+	_ = result.Listen(controllers...)
+	return result
+}
+
+func newTestModel(fields ...Field) Model {
+	if len(fields) == 0 {
+		fields = append(fields, INT64("id"))
+	}
+	login := TEXT("login", nil)
+	login.SetRequired(true)
+	fields = append(fields, login, TEXT("name"))
+	return NewModel("users", fields)
+}
+
+type testController struct {
+	path    string
+	id      string
+	model   Model
+	actions []Action
+}
+
+func (this *testController) Path() string {
+	return this.path
+}
+
+func (this *testController) Id() (string, string) {
+	return this.id, ""
+}
+
+func (this *testController) Model() Model {
+	return this.model
+}
+
+func (this *testController) Actions() []Action {
+	return this.actions
+}
+
+// driver with own dialect, schema and lock, executed queries are recorded, history tables are kept in memory
+func newTestDriver(dialect string) *testDriver {
+	result := testDriver{}
+	result.dialect = dialect
+	result.tables = make(map[string][]db.SQLColumn, 0)
+	result.history = make(map[string][]map[string]interface{}, 0)
+	return &result
+}
+
+type testDriver struct {
+	dialect  string
+	tables   map[string][]db.SQLColumn
+	rows     []map[string]interface{}
+	executed []string
+	history  map[string][]map[string]interface{} // created by CREATE TABLE IF NOT EXISTS
+	fail     string                              // Exec fails on queries containing it
+	written  map[string]interface{}              // unquoted columns of the last Insert or Update
+	filter   []db.SQLWhere                       // where of the last Update
+}
+
+func (this *testDriver) Select(table db.SQLTable, fields []db.SQLField, where []db.SQLWhere, groupBy []db.SQLGroupBy, having []db.SQLHaving, orderBy []db.SQLOrderBy, limit db.SQLLimit, offset db.SQLOffset) ([]map[string]interface{}, error) {
+	if strings.HasPrefix(table.Name(), "_migrations") {
+		return this.selectHistory(table.Name(), fields, where, orderBy)
+	}
+	if len(fields) == 1 && strings.HasPrefix(fields[0].Name(), "COUNT(") {
+		return []map[string]interface{}{{"cnt": int64(len(this.rows))}}, nil
+	}
+	return this.rows, nil
+}
+
+func (this *testDriver) Insert(table db.SQLTable, fields []db.SQLField) (interface{}, error) {
+	this.written = make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		this.written[strings.Trim(field.Name(), `"`)] = field.Value()
+	}
+	return int64(len(this.rows) + 1), nil
+}
+
+func (this *testDriver) Update(table db.SQLTable, fields []db.SQLField, where []db.SQLWhere) error {
+	if len(this.fail) > 0 && strings.Contains("UPDATE "+table.Name(), this.fail) {
+		return fmt.Errorf("failed: UPDATE %s", table.Name())
+	}
+	if !strings.HasPrefix(table.Name(), "_migrations") {
+		this.written = make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			this.written[strings.Trim(field.Name(), `"`)] = field.Value()
+		}
+		this.filter = where
+	}
+	for _, row := range this.history[table.Name()] {
+		if testWhere(row, where) {
+			for _, field := range fields {
+				row[field.Name()] = field.Value()
+			}
+		}
+	}
+	return nil
+}
+
+func (this *testDriver) Delete(table db.SQLTable, where []db.SQLWhere) error {
+	return nil
+}
+
+// all queries or none
+func (this *testDriver) Exec(query ...string) error {
+	for _, q := range query {
+		if len(this.fail) > 0 && strings.Contains(q, this.fail) {
+			return fmt.Errorf("failed: %s", q)
+		}
+	}
+	for _, q := range query {
+		this.executed = append(this.executed, q)
+		if m := testCreateTable.FindStringSubmatch(q); m != nil {
+			if _, ok := this.history[m[1]]; !ok {
+				this.history[m[1]] = make([]map[string]interface{}, 0)
+			}
+		} else if m := testInsert.FindStringSubmatch(q); m != nil {
+			row := make(map[string]interface{}, 0)
+			values := testValues(m[3])
+			for i, column := range strings.Split(m[2], ", ") {
+				row[column] = values[i]
+			}
+			this.history[m[1]] = append(this.history[m[1]], row)
+		} else if m := testDelete.FindStringSubmatch(q); m != nil {
+			rows := make([]map[string]interface{}, 0)
+			for _, row := range this.history[m[1]] {
+				if row["version"] != m[2] {
+					rows = append(rows, row)
+				}
+			}
+			this.history[m[1]] = rows
+		}
+	}
+	return nil
+}
+
+func (this *testDriver) Escape(value string) string {
+	return `"` + value + `"`
+}
+
+func (this *testDriver) Dialect() string {
+	return this.dialect
+}
+
+func (this *testDriver) Schema() db.Schema {
+	return this
+}
+
+func (this *testDriver) Lock(name string, timeout time.Duration) (func() error, error) {
+	this.executed = append(this.executed, "LOCK "+name)
+	return func() error {
+		this.executed = append(this.executed, "UNLOCK "+name)
+		return nil
+	}, nil
+}
+
+func (this *testDriver) Tables() ([]string, error) {
+	result := make([]string, 0, len(this.tables))
+	for name := range this.tables {
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+func (this *testDriver) Columns(table string) ([]db.SQLColumn, error) {
+	return this.tables[table], nil
+}
+
+func (this *testDriver) selectHistory(table string, fields []db.SQLField, where []db.SQLWhere, orderBy []db.SQLOrderBy) ([]map[string]interface{}, error) {
+	rows, ok := this.history[table]
+	if !ok {
+		return nil, fmt.Errorf("relation \"%s\" does not exist", table)
+	}
+	result := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if testWhere(row, where) {
+			copied := make(map[string]interface{}, len(row))
+			for k, v := range row {
+				copied[k] = v
+			}
+			result = append(result, copied)
+		}
+	}
+	if len(fields) == 1 && strings.HasPrefix(fields[0].Name(), "COUNT(") {
+		return []map[string]interface{}{{"cnt": len(result)}}, nil
+	}
+	if len(orderBy) > 0 {
+		desc := orderBy[0].Sort() == "DESC"
+		sort.SliceStable(result, func(i, j int) bool {
+			a, b := fmt.Sprintf("%020d %s", result[i]["apply_nano"], result[i]["version"]), fmt.Sprintf("%020d %s", result[j]["apply_nano"], result[j]["version"])
+			return (a < b) != desc
+		})
+	}
+	return result, nil
+}
+
+// transactions over a copy of the history tables
+type testDriverWithTx struct {
+	*testDriver
+	begun int
+}
+
+func (this *testDriverWithTx) Begin() (db.Tx, error) {
+	this.begun++
+	tx := &testTx{origin: this.testDriver}
+	copied := *this.testDriver
+	copied.executed = append([]string{}, this.executed...)
+	copied.history = make(map[string][]map[string]interface{}, len(this.history))
+	for name, rows := range this.history {
+		copied.history[name] = append([]map[string]interface{}{}, rows...)
+	}
+	tx.testDriver = &copied
+	return tx, nil
+}
+
+type testTx struct {
+	*testDriver
+	origin *testDriver
+}
+
+func (this *testTx) Commit() error {
+	this.origin.executed = this.executed
+	this.origin.history = this.history
+	return nil
+}
+
+func (this *testTx) Rollback() error {
+	return nil
+}
+
+var testCreateTable = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\S+) `)
+var testInsert = regexp.MustCompile(`^INSERT INTO (_migrations\S*) \(([^)]*)\) VALUES \((.*)\);$`)
+var testDelete = regexp.MustCompile(`^DELETE FROM (_migrations\S*) WHERE version = '([^']*)';$`)
+
+// escaped values of INSERT, strings and integers
+func testValues(values string) []interface{} {
+	result := make([]interface{}, 0)
+	for len(values) > 0 {
+		values = strings.TrimLeft(values, ", ")
+		if strings.HasPrefix(values, "'") {
+			value := ""
+			i := 1
+			for ; i < len(values); i++ {
+				if values[i] == '\'' && i+1 < len(values) && values[i+1] == '\'' {
+					value += "'"
+					i++
+				} else if values[i] == '\'' {
+					break
+				} else {
+					value += string(values[i])
+				}
+			}
+			result = append(result, value)
+			values = values[i+1:]
+		} else {
+			end := strings.Index(values, ",")
+			if end < 0 {
+				end = len(values)
+			}
+			n, _ := strconv.ParseInt(strings.TrimSpace(values[:end]), 10, 64)
+			result = append(result, n)
+			values = values[end:]
+		}
+	}
+	return result
+}
+
+func testWhere(row map[string]interface{}, where []db.SQLWhere) bool {
+	for _, w := range where {
+		if row[w.Field()] != w.Value() {
+			return false
+		}
+	}
+	return true
+}
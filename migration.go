@@ -2,59 +2,53 @@
 package grest
 
 import (
-	"database/sql"
 	"fmt"
 	"github.com/prorochestvo/grest/db"
 	"github.com/prorochestvo/grest/internal/dbase"
 	"github.com/prorochestvo/grest/internal/helper"
 	"sort"
 	"strings"
+	"time"
 )
 
 func NewMigration(driver db.Driver, router *Router) *migration {
 	result := migration{}
 	result.Table = "_migrations"
+	result.LockTimeout = time.Minute
+	result.LockExpiry = 5 * time.Minute
 	result.router = router
 	result.driver = driver
 	return &result
 }
 
 type migration struct {
-	Table  string
-	driver db.Driver
-	router *Router
+	Table       string
+	LockTimeout time.Duration // wait for other instances
+	LockExpiry  time.Duration // lock row lifetime, refreshed while held
+	DryRun      bool          // print the SQL plan to Router.Stdout instead of executing
+	driver      db.Driver
+	router      *Router
+	sources     []db.Migration
+	plan        *[]MigrationStep
 }
 
-func (this *migration) init() error {
-	query := []string{
-		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s ( version TEXT NOT NULL, apply_time INTEGER NOT NULL, PRIMARY KEY(version) );`, this.Table),
-	}
-	if err := this.driver.Exec(query...); err != nil {
-		return err
-	}
-	return nil
+/* merge migrations from other sources (e.g. db.NewMigrationsFromDir) with controller migrations */
+func (this *migration) Append(migrations ...db.Migration) {
+	this.sources = append(this.sources, migrations...)
 }
 
-func (this *migration) migrations() []db.Migration {
-	result := make([]db.Migration, 0)
-	for _, controller := range this.router.controllers {
-		if controller == nil {
-			continue
-		}
-		if c, ok := controller.(db.MigrationController); ok == true && c != nil {
-			result = append(result, c.Migrations()...)
-		}
+/* create or upgrade history tables, only by write paths under the lock (read paths use reader) */
+func (this *migration) init() error {
+	// dry run does not touch the database apart from reading history
+	if this.dry() {
+		return nil
 	}
-	sort.Slice(result, func(i, j int) bool { return result[i].Version() < result[j].Version() })
-	return result
+	return this.history().Init()
 }
 
 func (this *migration) Version() string {
-	if err := this.init(); err != nil {
-		return ""
-	}
 	result := ""
-	if ver, err := dbase.NewMigration(this.driver, this.Table).Version(); err != nil {
+	if ver, err := this.reader().Version(); err != nil {
 		_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
 	} else if len(ver) > 0 {
 		result = ver
@@ -62,99 +56,247 @@ func (this *migration) Version() string {
 	return result
 }
 
-/* exists migrations */
+/* exists migrations: true if applied and unchanged since, false if pending or changed (see Repair) */
 func (this *migration) Check() map[string]bool {
-	if err := this.init(); err != nil {
+	migrations, err := this.migrations()
+	if err != nil {
+		if this.router.Stderr != nil {
+			_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		}
 		return make(map[string]bool, 0)
 	}
-	migrations := this.migrations()
 	result := make(map[string]bool, len(migrations))
 	for _, m := range migrations {
 		result[m.Version()] = false
 	}
-	if history, err := dbase.NewMigration(this.driver, this.Table).History(); err != nil {
+	if checksums, err := this.reader().Checksums(); err != nil {
 		_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
-	} else if history != nil {
-		for _, version := range history {
+	} else if checksums != nil {
+		for version := range checksums {
+			result[version] = true
+		}
+		for _, version := range this.drifted(migrations, checksums) {
 			result[version] = false
+			if this.router.Stderr != nil {
+				_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("migration \"%s\" changed after being applied\n", version)))
+			}
 		}
 	}
 	return result
 }
 
-/* check fields and migration, return error is not exists */
-func (this *migration) Revise() error {
-	if err := this.init(); err != nil {
+/* re-baseline checksums of applied migrations (all if versions are omitted) after a deliberate edit */
+func (this *migration) Repair(versions ...string) error {
+	unlock, err := this.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = this.init(); err != nil {
+		return err
+	}
+	history := this.history()
+	checksums, err := history.Checksums()
+	if err != nil {
+		return err
+	}
+	migrations, err := this.migrations()
+	if err != nil {
 		return err
 	}
 	result := make([]string, 0)
-	// check migrations
-	if history, err := dbase.NewMigration(this.driver, this.Table).History(); err != nil {
+	for _, m := range migrations {
+		version := m.Version()
+		if len(versions) > 0 && helper.StringsIndexOf(versions, version) < 0 {
+			continue
+		}
+		checksum, ok := checksums[version]
+		if !ok || checksum == db.MigrationChecksum(m) {
+			continue
+		}
+		if err = history.Repair(version, db.MigrationChecksum(m)); err != nil {
+			result = append(result, err.Error())
+		} else if this.router.Stdout != nil {
+			_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\n", version)))
+		}
+	}
+	if len(result) > 0 {
+		return fmt.Errorf(strings.Join(result, "\n"))
+	}
+	return nil
+}
+
+/* applied migrations must be unchanged before running others (see Repair) */
+func (this *migration) verify(history dbase.Migration, migrations []db.Migration) error {
+	checksums, err := history.Checksums()
+	if err != nil {
 		return err
-	} else if history != nil {
-		migrations := this.migrations()
+	}
+	if drifted := this.drifted(migrations, checksums); len(drifted) > 0 {
+		return fmt.Errorf("migration \"%s\" changed after being applied, run Repair after a deliberate edit", strings.Join(drifted, "\", \""))
+	}
+	return nil
+}
+
+/* applied versions whose up/down sql differs from the recorded checksum, legacy rows without checksum are skipped */
+func (this *migration) drifted(migrations []db.Migration, checksums map[string]string) []string {
+	result := make([]string, 0)
+	for _, m := range migrations {
+		if checksum, ok := checksums[m.Version()]; ok && len(checksum) > 0 && checksum != db.MigrationChecksum(m) {
+			result = append(result, m.Version())
+		}
+	}
+	return result
+}
+
+/* check fields and migration, return *ReviseReport if schema differs from models */
+func (this *migration) Revise() error {
+	report, err := this.Report()
+	if err != nil {
+		return err
+	} else if report.Empty() {
+		return nil
+	}
+	return report
+}
+
+/* differences between models and database by the live schema, with the proposed fix (see Fix) */
+func (this *migration) Report() (*ReviseReport, error) {
+	result := &ReviseReport{}
+	// check migrations
+	if checksums, err := this.reader().Checksums(); err != nil {
+		return nil, err
+	} else if checksums != nil {
+		migrations, err := this.migrations()
+		if err != nil {
+			return nil, err
+		}
 		for _, m := range migrations {
 			version := m.Version()
-			if helper.StringsIndexOf(history, version) < 0 {
-				result = append(result, fmt.Sprintf("migration \"%s\" does not exist", version))
+			if _, ok := checksums[version]; !ok {
+				result.append(ReviseIssue{Version: version, Code: "migration", Message: fmt.Sprintf("migration \"%s\" does not exist", version)})
 			}
 		}
+		for _, version := range this.drifted(migrations, checksums) {
+			result.append(ReviseIssue{Version: version, Code: "checksum", Message: fmt.Sprintf("migration \"%s\" changed after being applied", version)})
+		}
 	}
-	// check fields
-	for _, controller := range this.router.controllers {
-		if controller == nil {
+	// check tables and columns
+	dialect := db.GetDialect(this.driver)
+	schema := db.GetSchema(this.driver)
+	exists, err := schema.Tables()
+	if err != nil {
+		return nil, err
+	}
+	tables := this.tables()
+	for _, table := range tables {
+		if !schemaHasTable(exists, table.name) {
+			result.append(ReviseIssue{Table: table.name, Code: "table", Message: fmt.Sprintf("table \"%s\" does not exist", table.name)})
 			continue
 		}
-		model := getControllerModel(controller)
-		if model == nil {
-			continue
+		columns, err := schema.Columns(table.name)
+		if err != nil {
+			return nil, err
+		}
+		primary := false
+		for _, live := range columns {
+			primary = primary || live.Primary()
 		}
-		if fields := model.Fields(); fields != nil && len(fields) > 0 {
-			wrong := make([]string, 0)
-			for _, field := range fields {
-				if _, err := this.driver.Select(db.NewSQLTable(model.Table()), []db.SQLField{db.NewSQLField(field.Name(), nil)}, nil, nil, nil, nil, db.NewSQLLimit(1), nil); err != nil && err != sql.ErrNoRows {
-					wrong = append(wrong, field.Name())
+		if !primary {
+			result.append(ReviseIssue{Table: table.name, Code: "primary", Message: fmt.Sprintf("table \"%s\" has no primary key", table.name)})
+		}
+		for _, column := range table.columns {
+			var live db.SQLColumn = nil
+			for _, c := range columns {
+				if c.Name() == column.name {
+					live = c
+					break
 				}
 			}
-			if wrong != nil && len(wrong) > 0 {
-				column := "column"
-				if len(wrong) > 1 {
-					column = "columns"
+			issue := ReviseIssue{Table: table.name, Column: column.name}
+			if expected := db.SQLType(dialect, column.kind); live == nil {
+				issue.Code = "column"
+				issue.Message = fmt.Sprintf("column \"%s\" does not exist in the \"%s\" table", column.name, table.name)
+			} else if column.primary && primary && !live.Primary() {
+				issue.Code = "primary"
+				issue.Message = fmt.Sprintf("column \"%s\" is not the primary key of the \"%s\" table", column.name, table.name)
+			} else if !db.SQLTypeCompatible(dialect, expected, live.Type()) {
+				issue.Code = "type"
+				issue.Message = fmt.Sprintf("column \"%s\" of the \"%s\" table is %s, expected %s", column.name, table.name, live.Type(), expected)
+			} else if column.required && live.Nullable() && !live.Primary() {
+				issue.Code = "nullable"
+				issue.Message = fmt.Sprintf("column \"%s\" of the \"%s\" table is nullable, but the field is required", column.name, table.name)
+			} else if column.nullable && !live.Nullable() {
+				issue.Code = "nullable"
+				issue.Message = fmt.Sprintf("column \"%s\" of the \"%s\" table is NOT NULL, but the field is nullable", column.name, table.name)
+			} else {
+				continue
+			}
+			result.append(issue)
+		}
+		for _, live := range columns {
+			known := false
+			for _, column := range table.columns {
+				if column.name == live.Name() {
+					known = true
+					break
 				}
-				sort.Strings(wrong)
-				result = append(result, fmt.Sprintf("%s \"%s\" does not exist in the \"%s\" table", column, strings.Join(wrong, "\", \""), model.Table()))
+			}
+			if !known {
+				result.append(ReviseIssue{Table: table.name, Column: live.Name(), Code: "unknown", Message: fmt.Sprintf("column \"%s\" of the \"%s\" table is not used by the model", live.Name(), table.name)})
 			}
 		}
 	}
-	// return all error
-	if result != nil && len(result) > 0 {
-		return fmt.Errorf(strings.Join(result, "\n"))
+	for _, name := range exists {
+		if strings.EqualFold(name, this.Table) || strings.EqualFold(name, this.Table+"_log") || strings.EqualFold(name, this.lockTable()) {
+			continue
+		}
+		known := false
+		for _, table := range tables {
+			if strings.EqualFold(name, table.name) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			result.append(ReviseIssue{Table: name, Code: "unknown", Message: fmt.Sprintf("table \"%s\" is not used by any model", name)})
+		}
 	}
-	return nil
+	// propose fix
+	if !result.Empty() {
+		if changes, err := this.Diff(); err == nil {
+			result.Fix = changes
+		}
+	}
+	return result, nil
 }
 
 func (this *migration) Up() error {
-	if err := this.init(); err != nil {
+	unlock, err := this.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = this.init(); err != nil {
 		return err
 	}
 	result := make([]string, 0)
-	migrations := this.migrations()
-	if history, err := dbase.NewMigration(this.driver, this.Table).History(); err != nil {
+	migrations, err := this.migrations()
+	if err != nil {
+		return err
+	}
+	history := this.history()
+	if err = this.verify(history, migrations); err != nil {
+		return err
+	}
+	if applied, err := history.History(); err != nil {
 		return err
 	} else {
 		for _, m := range migrations {
 			version := m.Version()
-			if helper.StringsIndexOf(history, version) < 0 {
-				if err := dbase.NewMigration(this.driver, this.Table).Append(version, m.Up()); err != nil {
-					if this.router.Stderr != nil {
-						_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n%s\n", m.Up(), err.Error())))
-					}
-					if this.router.Stdout != nil {
-						_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("⚠ %s\n\t%s\n", version, strings.ReplaceAll(err.Error(), "\n", " "))))
-					}
+			if helper.StringsIndexOf(applied, version) < 0 {
+				if err := this.apply(history, m); err != nil {
 					result = append(result, err.Error())
-				} else if this.router.Stdout != nil {
-					_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\n", version)))
 				}
 			} else if this.router.Stdout != nil {
 				_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\n", version)))
@@ -167,71 +309,119 @@ func (this *migration) Up() error {
 	return nil
 }
 
+/* rollback one migration step */
 func (this *migration) Down() error {
-	if err := this.init(); err != nil {
+	return this.DownN(1)
+}
+
+/* rollback n migration steps, latest applied first */
+func (this *migration) DownN(n int) error {
+	unlock, err := this.lock()
+	if err != nil {
 		return err
 	}
-	result := make([]string, 0)
-	migrations := this.migrations()
-	if current, err := dbase.NewMigration(this.driver, this.Table).Version(); err != nil {
+	defer unlock()
+	if err = this.init(); err != nil {
 		return err
-	} else {
-		for _, m := range migrations {
-			version := m.Version()
-			if current == version {
-				if err = dbase.NewMigration(this.driver, this.Table).Remove(version, m.Down()); err != nil {
-					if this.router.Stderr != nil {
-						_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n%s\n", m.Down(), err.Error())))
-					}
-					if this.router.Stdout != nil {
-						_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("⚠ %s\n\t%s\n", version, strings.ReplaceAll(err.Error(), "\n", " "))))
-					}
-					result = append(result, err.Error())
-				} else if this.router.Stdout != nil {
-					_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✘ %s\n", m.Version())))
-				}
+	}
+	migrations, err := this.migrations()
+	if err != nil {
+		return err
+	}
+	history := this.history()
+	if err = this.verify(history, migrations); err != nil {
+		return err
+	}
+	applied, err := history.History()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n && i < len(applied); i++ {
+		if err = this.revert(history, applied[i], migrations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* apply or rollback migrations in order until the target version is the latest applied one, empty version rollbacks all */
+func (this *migration) Goto(version string) error {
+	unlock, err := this.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = this.init(); err != nil {
+		return err
+	}
+	migrations, err := this.migrations()
+	if err != nil {
+		return err
+	}
+	// position of the target in migration order, -1 rollbacks all
+	target := -1
+	if len(version) > 0 {
+		for i, m := range migrations {
+			if m.Version() == version {
+				target = i
 				break
 			}
 		}
+		if target < 0 {
+			return fmt.Errorf("migration \"%s\" does not exist", version)
+		}
 	}
-	if len(result) > 0 {
-		return fmt.Errorf(strings.Join(result, "\n"))
+	history := this.history()
+	if err = this.verify(history, migrations); err != nil {
+		return err
+	}
+	applied, err := history.History()
+	if err != nil {
+		return err
+	}
+	// rollback newer versions, latest first
+	for i := len(migrations) - 1; i > target; i-- {
+		if v := migrations[i].Version(); helper.StringsIndexOf(applied, v) >= 0 {
+			if err = this.revert(history, v, migrations); err != nil {
+				return err
+			}
+		}
+	}
+	// apply older versions in order
+	for i := 0; i <= target; i++ {
+		if m := migrations[i]; helper.StringsIndexOf(applied, m.Version()) < 0 {
+			if err = this.apply(history, m); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
 func (this *migration) Reset() error {
-	if err := this.init(); err != nil {
+	unlock, err := this.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = this.init(); err != nil {
 		return err
 	}
 	result := make([]string, 0)
-	migrations := this.migrations()
-	if history, err := dbase.NewMigration(this.driver, this.Table).History(); err != nil {
+	migrations, err := this.migrations()
+	if err != nil {
+		return err
+	}
+	history := this.history()
+	if err = this.verify(history, migrations); err != nil {
+		return err
+	}
+	if applied, err := history.History(); err != nil {
 		return err
 	} else {
-		for _, version := range history {
-			var migration db.Migration = nil
-			for _, m := range migrations {
-				if version != m.Version() {
-					continue
-				}
-				migration = m
-				break
-			}
-			down := ""
-			if migration != nil {
-				down = migration.Down()
-			}
-			if err = this.driver.Exec(down, fmt.Sprintf(`DELETE FROM %s WHERE version = '%s';`, this.Table, version)); err != nil {
-				if this.router.Stderr != nil {
-					_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n%s\n", down, err.Error())))
-				}
-				if this.router.Stdout != nil {
-					_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("⚠ %s\n\t%s\n", version, strings.ReplaceAll(err.Error(), "\n", " "))))
-				}
+		for _, version := range applied {
+			if err = this.revert(history, version, migrations); err != nil {
 				result = append(result, err.Error())
-			} else if this.router.Stdout != nil {
-				_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✘ %s\n", version)))
 			}
 		}
 	}
@@ -240,3 +430,256 @@ func (this *migration) Reset() error {
 	}
 	return nil
 }
+
+/* applied migrations in order of application */
+func (this *migration) History() ([]MigrationRecord, error) {
+	records, err := this.reader().Records()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MigrationRecord, 0, len(records))
+	for _, record := range records {
+		result = append(result, MigrationRecord{
+			Version:   record.Version,
+			AppliedAt: getMigrationTime(record),
+			Duration:  time.Duration(record.Duration),
+			Host:      record.Host,
+			User:      record.User,
+			Checksum:  record.Checksum,
+		})
+	}
+	return result, nil
+}
+
+/* successful and failed up/down attempts in order (all versions if version is empty) */
+func (this *migration) Attempts(version string) ([]MigrationAttempt, error) {
+	attempts, err := this.reader().Attempts(version)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MigrationAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		result = append(result, MigrationAttempt{
+			Version:   attempt.Version,
+			Direction: attempt.Direction,
+			At:        time.Unix(0, attempt.ApplyNano).UTC(),
+			Duration:  time.Duration(attempt.Duration),
+			Host:      attempt.Host,
+			User:      attempt.User,
+			Error:     attempt.Error,
+		})
+	}
+	return result, nil
+}
+
+/* applied and pending migrations in order, applied versions without source at the end */
+func (this *migration) Status() ([]MigrationStatus, error) {
+	records, err := this.reader().Records()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]dbase.Record, len(records))
+	checksums := make(map[string]string, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+		checksums[record.Version] = record.Checksum
+	}
+	migrations, err := this.migrations()
+	if err != nil {
+		return nil, err
+	}
+	drifted := this.drifted(migrations, checksums)
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version()}
+		if record, ok := applied[status.Version]; ok {
+			t := getMigrationTime(record)
+			status.Applied = true
+			status.AppliedAt = &t
+			status.Changed = helper.StringsIndexOf(drifted, status.Version) >= 0
+			delete(applied, status.Version)
+		}
+		result = append(result, status)
+	}
+	orphans := make([]string, 0, len(applied))
+	for version := range applied {
+		orphans = append(orphans, version)
+	}
+	sort.Strings(orphans)
+	for _, version := range orphans {
+		t := getMigrationTime(applied[version])
+		result = append(result, MigrationStatus{Version: version, Applied: true, AppliedAt: &t, Orphan: true})
+	}
+	return result, nil
+}
+
+func (this *migration) apply(history dbase.Migration, m db.Migration) error {
+	version := m.Version()
+	up := getMigrationSQL(m, m.Up())
+	start := time.Now()
+	var err error = nil
+	if f, ok := m.(db.FuncMigration); ok && f != nil && !this.dry() {
+		err = this.call(history, f.UpFunc, func(h dbase.Migration) error { return h.Append(version, "", db.MigrationChecksum(m), start) })
+	} else {
+		err = history.Append(version, up, db.MigrationChecksum(m), start)
+	}
+	this.attempt(history, version, "up", start, err)
+	if err != nil {
+		if this.router.Stderr != nil {
+			_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n%s\n", up, err.Error())))
+		}
+		if this.router.Stdout != nil {
+			_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("⚠ %s\n\t%s\n", version, strings.ReplaceAll(err.Error(), "\n", " "))))
+		}
+		return err
+	} else if this.router.Stdout != nil && this.dry() {
+		_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\n%s\n", version, up)))
+	} else if this.router.Stdout != nil {
+		_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\n", version)))
+	}
+	return nil
+}
+
+/* rollback applied version, only the history record is removed if the migration source does not exist */
+func (this *migration) revert(history dbase.Migration, version string, migrations []db.Migration) error {
+	var migration db.Migration = nil
+	down := ""
+	for _, m := range migrations {
+		if m.Version() == version {
+			migration = m
+			down = getMigrationSQL(m, m.Down())
+			break
+		}
+	}
+	start := time.Now()
+	var err error = nil
+	if f, ok := migration.(db.FuncMigration); ok && f != nil && !this.dry() {
+		err = this.call(history, f.DownFunc, func(h dbase.Migration) error { return h.Remove(version, "") })
+	} else {
+		err = history.Remove(version, down)
+	}
+	this.attempt(history, version, "down", start, err)
+	if err != nil {
+		if this.router.Stderr != nil {
+			_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n%s\n", down, err.Error())))
+		}
+		if this.router.Stdout != nil {
+			_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("⚠ %s\n\t%s\n", version, strings.ReplaceAll(err.Error(), "\n", " "))))
+		}
+		return err
+	} else if this.router.Stdout != nil && this.dry() {
+		_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✘ %s\n%s\n", version, down)))
+	} else if this.router.Stdout != nil {
+		_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✘ %s\n", version)))
+	}
+	return nil
+}
+
+/* log the attempt outcome, the log never fails a migration */
+func (this *migration) attempt(history dbase.Migration, version, direction string, start time.Time, err error) {
+	if e := history.Attempt(version, direction, start, err); e != nil && this.router.Stderr != nil {
+		_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n", e.Error())))
+	}
+}
+
+/* run Go function migration and its history record in one transaction if the driver supports it */
+func (this *migration) call(history dbase.Migration, run func(db.Driver) error, record func(dbase.Migration) error) error {
+	if d, ok := this.driver.(db.DriverWithTx); ok && d != nil {
+		tx, err := d.Begin()
+		if err != nil {
+			return err
+		}
+		if err = run(tx); err == nil {
+			err = record(dbase.NewMigration(tx, this.Table))
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+	if err := run(this.driver); err != nil {
+		return err
+	}
+	return record(history)
+}
+
+type MigrationRecord struct {
+	Version   string        `json:"version"`
+	AppliedAt time.Time     `json:"applied_at"`
+	Duration  time.Duration `json:"duration"`
+	Host      string        `json:"host"`
+	User      string        `json:"user"`
+	Checksum  string        `json:"checksum"`
+}
+
+type MigrationAttempt struct {
+	Version   string        `json:"version"`
+	Direction string        `json:"direction"`
+	At        time.Time     `json:"at"`
+	Duration  time.Duration `json:"duration"`
+	Host      string        `json:"host"`
+	User      string        `json:"user"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type MigrationStatus struct {
+	Version   string     `json:"version"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Changed   bool       `json:"changed,omitempty"`
+	Orphan    bool       `json:"orphan,omitempty"`
+}
+
+type ReviseIssue struct {
+	Version string `json:"version,omitempty"`
+	Table   string `json:"table,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ReviseReport struct {
+	Issues []ReviseIssue  `json:"issues"`
+	Fix    []db.Migration `json:"-"`
+}
+
+func (this *ReviseReport) Empty() bool {
+	return len(this.Issues) == 0
+}
+
+// one line per issue and per proposed fix
+func (this *ReviseReport) Error() string {
+	result := make([]string, 0, len(this.Issues)+len(this.Fix))
+	for _, issue := range this.Issues {
+		result = append(result, issue.Message)
+	}
+	for _, m := range this.Fix {
+		result = append(result, fmt.Sprintf("fix %s: %s", m.Version(), strings.ReplaceAll(m.Up(), "\n", " ")))
+	}
+	return strings.Join(result, "\n")
+}
+
+func (this *ReviseReport) append(issue ReviseIssue) {
+	this.Issues = append(this.Issues, issue)
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+// Go function migrations have no SQL, placeholder for the dry run
+func getMigrationSQL(m db.Migration, sql string) string {
+	if f, ok := m.(db.FuncMigration); ok && f != nil && len(strings.TrimSpace(sql)) == 0 {
+		return fmt.Sprintf("-- go function migration %s", m.Version())
+	}
+	return sql
+}
+
+// nanosecond precision for records with apply_nano
+func getMigrationTime(record dbase.Record) time.Time {
+	if record.ApplyNano > 0 {
+		return time.Unix(0, record.ApplyNano).UTC()
+	}
+	return time.Unix(record.ApplyTime, 0).UTC()
+}
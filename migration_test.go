@@ -0,0 +1,419 @@
+package grest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrationLock(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	if err := router.Migration.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// history tables are created by the lock holder only
+	if len(driver.executed) < 3 || driver.executed[0] != "LOCK _migrations" || driver.executed[len(driver.executed)-1] != "UNLOCK _migrations" {
+		t.Fatalf("migration[lock]: wrong order\n%s", strings.Join(driver.executed, "\n"))
+	}
+	for _, query := range driver.executed[1 : len(driver.executed)-1] {
+		if strings.Contains(query, "LOCK") {
+			t.Errorf("migration[lock]: unexpected lock %s", query)
+		}
+	}
+	// read paths neither lock nor change history tables
+	driver = newTestDriver(db.DialectPostgreSQL)
+	router = newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	router.Migration.Append(db.NewMigration("0001_users", "CREATE TABLE users (id BIGINT);", "DROP TABLE users;"))
+	_ = router.Migration.Version()
+	_ = router.Migration.Check()
+	_, _ = router.Migration.History()
+	_, _ = router.Migration.Attempts("")
+	_, _ = router.Migration.Status()
+	_, _ = router.Migration.Report()
+	if len(driver.executed) != 0 {
+		t.Errorf("migration[lock]: unexpected queries of read paths\n%s", strings.Join(driver.executed, "\n"))
+	} else if _, ok := driver.history[router.Migration.Table]; ok {
+		t.Errorf("migration[lock]: history table is created by read paths")
+	}
+}
+
+func TestMigrationReport(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	// primary key on the other column
+	driver.tables["users"] = []db.SQLColumn{
+		db.NewSQLColumn("id", "bigint", false, false),
+		db.NewSQLColumn("login", "text", false, true),
+		db.NewSQLColumn("name", "text", true, false),
+	}
+	report, err := router.Migration.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 {
+		t.Fatalf("migration[report]: wrong issues %v", report.Issues)
+	} else if issue := report.Issues[0]; issue.Code != "primary" || issue.Table != "users" || issue.Column != "id" {
+		t.Errorf("migration[report]: wrong issue %v", issue)
+	}
+	// no primary key
+	driver.tables["users"] = []db.SQLColumn{
+		db.NewSQLColumn("id", "bigint", false, false),
+		db.NewSQLColumn("login", "text", false, false),
+		db.NewSQLColumn("name", "text", true, false),
+	}
+	if report, err = router.Migration.Report(); err != nil {
+		t.Fatal(err)
+	} else if len(report.Issues) != 1 || report.Issues[0].Code != "primary" || report.Issues[0].Column != "" {
+		t.Errorf("migration[report]: wrong issues %v", report.Issues)
+	}
+	// matching primary key
+	driver.tables["users"] = []db.SQLColumn{
+		db.NewSQLColumn("id", "bigint", false, true),
+		db.NewSQLColumn("login", "text", false, false),
+		db.NewSQLColumn("name", "text", true, false),
+	}
+	if report, err = router.Migration.Report(); err != nil {
+		t.Fatal(err)
+	} else if !report.Empty() {
+		t.Errorf("migration[report]: unexpected issues %v", report.Issues)
+	}
+}
+
+func TestMigrationPlan(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	calls := 0
+	router.Migration.Append(db.NewFuncMigration("m9001", func(d db.Driver) error { calls++; return nil }, nil))
+	steps, err := router.Migration.PlanUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var step *MigrationStep = nil
+	for i := range steps {
+		if steps[i].Version == "m9001" {
+			step = &steps[i]
+		}
+	}
+	if step == nil {
+		t.Fatalf("migration[plan]: missing step %v", steps)
+	} else if step.SQL != "-- go function migration m9001" {
+		t.Errorf("migration[plan]: wrong sql «%s»", step.SQL)
+	} else if calls != 0 {
+		t.Errorf("migration[plan]: function called in the dry run")
+	}
+}
+
+func TestMigrationCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err = ioutil.WriteFile(filepath.Join(dir, "0001_extra.up.sql"), []byte("CREATE TABLE extra (id BIGINT);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	stdout := bytes.NewBuffer(nil)
+	router.Stdout = stdout
+	// files of -dir are planned with the controller migrations
+	if code := MigrationCLI(router, []string{"-dry-run", "-dir", dir, "up"}); code != MigrationExitOK {
+		t.Fatalf("migration[cli]: wrong exit code %d", code)
+	} else if !strings.Contains(stdout.String(), "✔ 0001_extra\nCREATE TABLE extra (id BIGINT);") {
+		t.Errorf("migration[cli]: missing file migration\n%s", stdout.String())
+	} else if len(router.Migration.sources) != 0 {
+		t.Errorf("migration[cli]: sources are not restored")
+	}
+	// already appended by main
+	files, _ := db.NewMigrationsFromDir(dir)
+	router.Migration.Append(files...)
+	if code := MigrationCLI(router, []string{"-dry-run", "-dir", dir, "up"}); code != MigrationExitOK {
+		t.Errorf("migration[cli]: wrong exit code %d for appended files", code)
+	}
+	// flags after the command
+	stdout.Reset()
+	executed := len(driver.executed)
+	if code := MigrationCLI(router, []string{"up", "-dry-run", "-dir", dir}); code != MigrationExitOK {
+		t.Errorf("migration[cli]: wrong exit code %d for flags after the command", code)
+	} else if !strings.Contains(stdout.String(), "CREATE TABLE extra") || len(driver.executed) != executed {
+		t.Errorf("migration[cli]: wrong dry run\n%s", stdout.String())
+	}
+	if code := MigrationCLI(router, []string{"down", "-dry-run", "2"}); code != MigrationExitOK {
+		t.Errorf("migration[cli]: wrong exit code %d for flags between arguments", code)
+	}
+	if code := MigrationCLI(router, []string{"seed", "-dry-run"}); code != MigrationExitUsage {
+		t.Errorf("migration[cli]: wrong exit code %d for seed in the dry run", code)
+	}
+	if code := MigrationCLI(router, []string{"up", "-unknown"}); code != MigrationExitUsage {
+		t.Errorf("migration[cli]: wrong exit code %d for an unknown flag", code)
+	}
+	// missing directories
+	if code := MigrationCLI(router, []string{"-dry-run", "status"}); code != MigrationExitOK {
+		t.Errorf("migration[cli]: wrong exit code %d for the missing default directory", code)
+	}
+	if code := MigrationCLI(router, []string{"-dir", filepath.Join(dir, "missing"), "status"}); code != MigrationExitError {
+		t.Errorf("migration[cli]: wrong exit code %d for the missing directory", code)
+	}
+}
+
+func TestMigrationDuration(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	router.Migration.Append(db.NewMigration("0001_users", "CREATE TABLE users (id BIGINT);", "DROP TABLE users;"))
+	router.Migration.Append(db.NewFuncMigration("0002_func", func(d db.Driver) error { time.Sleep(time.Millisecond); return nil }, nil))
+	// the follow-up duration write is not the migration outcome
+	driver.fail = "UPDATE _migrations"
+	if err := router.Migration.Up(); err != nil {
+		t.Fatalf("migration[duration]: %s", err.Error())
+	}
+	records, err := router.Migration.History()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(records) != 2 {
+		t.Fatalf("migration[duration]: wrong records %v", records)
+	} else if records[1].Duration < time.Millisecond {
+		t.Errorf("migration[duration]: wrong duration %s of the function migration", records[1].Duration)
+	}
+	attempts, err := router.Migration.Attempts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, attempt := range attempts {
+		if len(attempt.Error) > 0 {
+			t.Errorf("migration[duration]: failed attempt %v", attempt)
+		}
+	}
+}
+
+func TestMigrationOrder(t *testing.T) {
+	sql := func(version string, dependencies ...string) db.Migration {
+		return db.MigrationAfter(db.NewMigration(version, "SELECT 1;", "SELECT 1;"), dependencies...)
+	}
+	cases := []struct {
+		name       string
+		migrations map[string][]db.Migration // by controller path
+		expected   string                    // order or error
+	}{
+		{"namespace", map[string][]db.Migration{
+			"users":    {sql("0002_users"), sql("0003_users_email")},
+			"sessions": {sql("0001_sessions", "users")},
+		}, "0002_users, 0003_users_email, 0001_sessions"},
+		{"version", map[string][]db.Migration{
+			"users":    {sql("0002_users"), sql("0004_users_email")},
+			"sessions": {sql("0001_sessions", "users@0002_users")},
+		}, "0002_users, 0001_sessions, 0004_users_email"},
+		{"unknown dependency", map[string][]db.Migration{
+			"sessions": {sql("0001_sessions", "accounts")},
+		}, `migration "sessions@0001_sessions" depends on unknown "accounts"`},
+		{"cycle", map[string][]db.Migration{
+			"users":    {sql("0001_users", "sessions")},
+			"sessions": {sql("0002_sessions", "users")},
+			"audit":    {sql("0003_audit", "sessions")},
+		}, "migration dependency cycle: users@0001_users -> sessions@0002_sessions -> users@0001_users"},
+	}
+	for _, c := range cases {
+		controllers := make([]Controller, 0)
+		for _, path := range []string{"users", "sessions", "audit"} {
+			if migrations, ok := c.migrations[path]; ok {
+				controllers = append(controllers, &testMigrationController{testController{path: path, model: newTestModel()}, migrations})
+			}
+		}
+		router := newTestRouter(newTestDriver(db.DialectPostgreSQL), controllers...)
+		result := ""
+		if migrations, err := router.Migration.migrations(); err != nil {
+			result = err.Error()
+		} else {
+			versions := make([]string, 0, len(migrations))
+			for _, m := range migrations {
+				versions = append(versions, m.Version())
+			}
+			result = strings.Join(versions, ", ")
+		}
+		if result != c.expected {
+			t.Errorf("migration[order:%s]: wrong result «%s», must be «%s»", c.name, result, c.expected)
+		}
+	}
+}
+
+func TestMigrationChecksum(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	router.Migration.Append(db.NewMigration("0001_users", "CREATE TABLE users (id BIGINT);", "DROP TABLE users;"))
+	if err := router.Migration.Up(); err != nil {
+		t.Fatal(err)
+	} else if check := router.Migration.Check(); !check["0001_users"] {
+		t.Fatalf("migration[checksum]: wrong check %v", check)
+	}
+	// edited after being applied
+	router.Migration.sources = nil
+	router.Migration.Append(db.NewMigration("0001_users", "CREATE TABLE users (id BIGINT, login TEXT);", "DROP TABLE users;"))
+	router.Migration.Append(db.NewMigration("0002_sessions", "CREATE TABLE sessions (id BIGINT);", "DROP TABLE sessions;"))
+	if check := router.Migration.Check(); check["0001_users"] {
+		t.Errorf("migration[checksum]: drift is not detected %v", check)
+	}
+	if report, err := router.Migration.Report(); err != nil {
+		t.Fatal(err)
+	} else if !testHasIssue(report, "checksum", "0001_users") {
+		t.Errorf("migration[checksum]: missing checksum issue %v", report.Issues)
+	}
+	executed := len(driver.executed)
+	if err := router.Migration.Up(); err == nil || !strings.Contains(err.Error(), `"0001_users" changed`) {
+		t.Errorf("migration[checksum]: Up must refuse, error %v", err)
+	} else if strings.Contains(strings.Join(driver.executed[executed:], "\n"), "sessions") {
+		t.Errorf("migration[checksum]: pending migration applied\n%s", strings.Join(driver.executed[executed:], "\n"))
+	}
+	// re-baseline
+	if err := router.Migration.Repair(); err != nil {
+		t.Fatal(err)
+	} else if check := router.Migration.Check(); !check["0001_users"] || check["0002_sessions"] {
+		t.Errorf("migration[repair]: wrong check %v", check)
+	}
+	if report, err := router.Migration.Report(); err != nil {
+		t.Fatal(err)
+	} else if testHasIssue(report, "checksum", "0001_users") {
+		t.Errorf("migration[repair]: checksum issue is not cleared %v", report.Issues)
+	}
+	if err := router.Migration.Up(); err != nil {
+		t.Errorf("migration[repair]: %s", err.Error())
+	} else if check := router.Migration.Check(); !check["0002_sessions"] {
+		t.Errorf("migration[repair]: pending migration is not applied %v", check)
+	}
+}
+
+func TestMigrationGoto(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestModel()})
+	for _, version := range []string{"0001_a", "0002_b", "0003_c"} {
+		router.Migration.Append(db.NewMigration(version, "CREATE TABLE "+version+";", "DROP TABLE "+version+";"))
+	}
+	applied := func() string {
+		status, err := router.Migration.Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := make([]string, 0)
+		for _, s := range status {
+			if s.Applied {
+				result = append(result, s.Version)
+			}
+		}
+		return strings.Join(result, ",")
+	}
+	cases := []struct {
+		name     string
+		run      func() error
+		expected string // applied versions or error
+	}{
+		{"goto forward", func() error { return router.Migration.Goto("0002_b") }, "0001_a,0002_b"},
+		{"goto latest", func() error { return router.Migration.Goto("0003_c") }, "0001_a,0002_b,0003_c"},
+		{"goto backward", func() error { return router.Migration.Goto("0001_a") }, "0001_a"},
+		{"goto unknown", func() error { return router.Migration.Goto("0009_x") }, `migration "0009_x" does not exist`},
+		{"up", router.Migration.Up, "0001_a,0002_b,0003_c"},
+		{"down", func() error { return router.Migration.DownN(2) }, "0001_a"},
+		{"down more than applied", func() error { return router.Migration.DownN(5) }, ""},
+		{"down nothing applied", func() error { return router.Migration.DownN(1) }, ""},
+		{"goto empty", func() error { _ = router.Migration.Up(); return router.Migration.Goto("") }, ""},
+	}
+	for _, c := range cases {
+		result := ""
+		if err := c.run(); err != nil {
+			result = err.Error()
+		} else {
+			result = applied()
+		}
+		if result != c.expected {
+			t.Errorf("migration[%s]: wrong result «%s», must be «%s»", c.name, result, c.expected)
+		}
+	}
+	// latest applied first
+	if executed := strings.Join(driver.executed, "\n"); strings.Index(executed, "DROP TABLE 0003_c;") > strings.Index(executed, "DROP TABLE 0002_b;") {
+		t.Errorf("migration[down]: wrong order\n%s", strings.Join(driver.executed, "\n"))
+	}
+}
+
+func TestMigrationFunc(t *testing.T) {
+	fail := fmt.Errorf("backfill failed")
+	cases := []struct {
+		name string
+		tx   bool
+		err  error
+		// queries of the function and the history row after Up
+		executed bool
+		applied  bool
+	}{
+		{"tx failed", true, fail, false, false},
+		{"tx", true, nil, true, true},
+		{"without tx failed", false, fail, true, false},
+		{"without tx", false, nil, true, true},
+	}
+	for _, c := range cases {
+		driver := newTestDriver(db.DialectPostgreSQL)
+		var d db.Driver = driver
+		tx := &testDriverWithTx{testDriver: driver}
+		if c.tx {
+			d = tx
+		}
+		router := newTestRouter(d, &testController{path: "users", model: newTestModel()})
+		err := c.err
+		router.Migration.Append(db.NewFuncMigration("0001_backfill", func(d db.Driver) error {
+			if e := d.Exec("UPDATE users SET name = login;"); e != nil {
+				return e
+			}
+			return err
+		}, func(d db.Driver) error {
+			return fail
+		}))
+		if e := router.Migration.Up(); (e != nil) != (c.err != nil) {
+			t.Errorf("migration[func:%s]: wrong error %v", c.name, e)
+		}
+		driver = tx.testDriver
+		executed := strings.Contains(strings.Join(driver.executed, "\n"), "UPDATE users SET name = login;")
+		check := router.Migration.Check()
+		if executed != c.executed || check["0001_backfill"] != c.applied {
+			t.Errorf("migration[func:%s]: wrong executed %t or applied %t\n%s", c.name, executed, check["0001_backfill"], strings.Join(driver.executed, "\n"))
+		} else if c.tx && tx.begun != 1 {
+			t.Errorf("migration[func:%s]: %d transactions", c.name, tx.begun)
+		}
+		attempts, _ := router.Migration.Attempts("0001_backfill")
+		if len(attempts) != 1 || (len(attempts[0].Error) > 0) != (c.err != nil) {
+			t.Errorf("migration[func:%s]: wrong attempts %v", c.name, attempts)
+		}
+		// failed rollback keeps the history row
+		if c.applied {
+			if e := router.Migration.Down(); e == nil {
+				t.Errorf("migration[func:%s]: down error expected", c.name)
+			} else if check := router.Migration.Check(); !check["0001_backfill"] {
+				t.Errorf("migration[func:%s]: history row removed by the failed down", c.name)
+			}
+		}
+	}
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+func testHasIssue(report *ReviseReport, code, version string) bool {
+	for _, issue := range report.Issues {
+		if issue.Code == code && issue.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+type testMigrationController struct {
+	testController
+	migrations []db.Migration
+}
+
+func (this *testMigrationController) Migrations() []db.Migration {
+	return this.migrations
+}
@@ -0,0 +1,459 @@
+package grest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prorochestvo/grest/internal/helper"
+	"github.com/prorochestvo/grest/usr"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Model by struct tags
+ *
+ *   type User struct {
+ *     ID    int64     `grest:"id,int64,perm=1:ro;2:ro"`
+ *     Login string    `grest:"login,,perm=1:rw;2:ro,required,len=3:32"`
+ *     Email *string   `grest:"email,text,perm=1:rw,email"`
+ *     Born  time.Time `grest:"born,date,perm=1:rw,column=born_at"`
+ *     Skip  string    `grest:"-"`
+ *   }
+ *
+ *   fields of embedded structs without the grest tag are promoted
+ *
+ *   NAME                   // field name, default: lower case struct field name
+ *   TYPE                   // int8 ... uint64, float32, float64, decimal, text, boolean, datetime, date, time, uuid, enum, bytes, array:TYPE
+ *                          // default: by struct field type
+ *   perm=ROLE:ro|wo|rw;... // permissions
+ *   required, nullable     // options (pointer struct field is nullable)
+ *   column=NAME            // database column
+ *   format=LAYOUT          // datetime layout, default: RFC3339
+ *   values=A|B|C           // enum values
+ *   min=N, max=N           // RuleMin, RuleMax
+ *   len=MIN:MAX            // RuleLength (MAX is optional)
+ *   email                  // RuleEmail
+ *   regex=PATTERN          // RuleRegex (without commas)
+ */
+func NewModelFromStruct(table string, value interface{}, extraFields ...ExtraField) (Model, error) {
+	t := reflect.TypeOf(value)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", helper.TypeName(value))
+	}
+	fields := make([]Field, 0)
+	for _, sf := range getStructFields(t) {
+		field, err := newStructField(sf, sf.Tag.Get("grest"))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", t.Name(), sf.Name, err.Error())
+		}
+		fields = append(fields, field)
+	}
+	return NewModel(table, fields, extraFields...), nil
+}
+
+// rows (by field names) into pointer to struct or pointer to slice of structs
+func ScanRows(rows []map[string]interface{}, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("destination must be a non-nil pointer")
+	}
+	v = v.Elem()
+	if v.Kind() == reflect.Struct {
+		if len(rows) == 0 {
+			return fmt.Errorf("empty dataset")
+		}
+		return scanStructRow(rows[0], v)
+	} else if v.Kind() == reflect.Slice {
+		t := v.Type().Elem()
+		items := reflect.MakeSlice(v.Type(), 0, len(rows))
+		for _, row := range rows {
+			item := reflect.New(t).Elem()
+			target := item
+			if t.Kind() == reflect.Ptr {
+				item = reflect.New(t.Elem())
+				target = item.Elem()
+			}
+			if target.Kind() != reflect.Struct {
+				return fmt.Errorf("destination must be a slice of structs")
+			}
+			if err := scanStructRow(row, target); err != nil {
+				return err
+			}
+			items = reflect.Append(items, item)
+		}
+		v.Set(items)
+		return nil
+	}
+	return fmt.Errorf("destination must be a pointer to struct or slice")
+}
+
+/***********************************************************************************************************************
+ * helper
+ */
+func newStructField(sf reflect.StructField, tag string) (FieldEx, error) {
+	parts := strings.Split(tag, ",")
+	name := strings.ToLower(sf.Name)
+	if len(parts[0]) > 0 {
+		name = parts[0]
+	}
+	kind := ""
+	if len(parts) > 1 {
+		kind = strings.ToLower(parts[1])
+	}
+	options := make(map[string]string, 0)
+	if len(parts) > 2 {
+		for _, o := range parts[2:] {
+			kv := strings.SplitN(o, "=", 2)
+			if len(kv) == 2 {
+				options[strings.ToLower(kv[0])] = kv[1]
+			} else if len(kv[0]) > 0 {
+				options[strings.ToLower(kv[0])] = ""
+			}
+		}
+	}
+	permissions, err := parseStructPermissions(options["perm"])
+	if err != nil {
+		return nil, err
+	}
+	t := sf.Type
+	nullable := false
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+	if len(kind) == 0 {
+		if kind = getStructFieldType(t); len(kind) == 0 {
+			return nil, fmt.Errorf("unknown type %s", sf.Type.String())
+		}
+	}
+	field, err := newFieldByType(name, kind, options, permissions...)
+	if err != nil {
+		return nil, err
+	}
+	// options
+	rules := make([]Rule, 0)
+	if _, ok := options["required"]; ok {
+		field.SetRequired(true)
+	}
+	if _, ok := options["nullable"]; ok || nullable {
+		field.SetNullable(true)
+	}
+	if column, ok := options["column"]; ok && len(column) > 0 {
+		field.SetColumn(column)
+	}
+	if v, ok := options["min"]; ok {
+		min, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("wrong min %s", v)
+		}
+		rules = append(rules, RuleMin(min))
+	}
+	if v, ok := options["max"]; ok {
+		max, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("wrong max %s", v)
+		}
+		rules = append(rules, RuleMax(max))
+	}
+	if v, ok := options["len"]; ok {
+		l := strings.SplitN(v, ":", 2)
+		min, err := strconv.Atoi(l[0])
+		if err != nil {
+			return nil, fmt.Errorf("wrong len %s", v)
+		}
+		max := -1
+		if len(l) == 2 && len(l[1]) > 0 {
+			if max, err = strconv.Atoi(l[1]); err != nil {
+				return nil, fmt.Errorf("wrong len %s", v)
+			}
+		}
+		rules = append(rules, RuleLength(min, max))
+	}
+	if _, ok := options["email"]; ok {
+		rules = append(rules, RuleEmail())
+	}
+	if v, ok := options["regex"]; ok {
+		if _, err := regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("wrong regex %s", v)
+		}
+		rules = append(rules, RuleRegex(v))
+	}
+	if len(rules) > 0 {
+		field.SetRules(rules...)
+	}
+	return field, nil
+}
+
+func newFieldByType(name, kind string, options map[string]string, permission ...usr.Permission) (FieldEx, error) {
+	switch kind {
+	case "int8":
+		return INT8(name, permission...), nil
+	case "int16":
+		return INT16(name, permission...), nil
+	case "int32":
+		return INT32(name, permission...), nil
+	case "int64":
+		return INT64(name, permission...), nil
+	case "uint8":
+		return UINT8(name, permission...), nil
+	case "uint16":
+		return UINT16(name, permission...), nil
+	case "uint32":
+		return UINT32(name, permission...), nil
+	case "uint64":
+		return UINT64(name, permission...), nil
+	case "float32":
+		return FLOAT32(name, permission...), nil
+	case "float64":
+		return FLOAT64(name, permission...), nil
+	case "decimal":
+		return DECIMAL(name, permission...), nil
+	case "text":
+		return TEXT(name, permission...), nil
+	case "boolean", "bool":
+		return BOOLEAN(name, permission...), nil
+	case "datetime":
+		format := time.RFC3339
+		if f, ok := options["format"]; ok && len(f) > 0 {
+			format = f
+		}
+		return DATETIME(name, format, permission...), nil
+	case "date":
+		return DATE(name, permission...), nil
+	case "time":
+		return TIME(name, permission...), nil
+	case "uuid":
+		return UUID(name, permission...), nil
+	case "enum":
+		values, ok := options["values"]
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("missing enum values")
+		}
+		return ENUM(name, strings.Split(values, "|"), permission...), nil
+	case "bytes":
+		return BYTES(name, permission...), nil
+	}
+	if strings.HasPrefix(kind, "array:") {
+		of, err := newFieldByType(name, strings.TrimPrefix(kind, "array:"), options)
+		if err != nil {
+			return nil, err
+		}
+		return ARRAY(name, of, permission...), nil
+	}
+	return nil, fmt.Errorf("unknown type %s", kind)
+}
+
+func getStructFieldType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "datetime"
+	} else if t == reflect.TypeOf(json.Number("")) {
+		return "decimal"
+	}
+	switch t.Kind() {
+	case reflect.Int8:
+		return "int8"
+	case reflect.Int16:
+		return "int16"
+	case reflect.Int32:
+		return "int32"
+	case reflect.Int, reflect.Int64:
+		return "int64"
+	case reflect.Uint8:
+		return "uint8"
+	case reflect.Uint16:
+		return "uint16"
+	case reflect.Uint32:
+		return "uint32"
+	case reflect.Uint, reflect.Uint64:
+		return "uint64"
+	case reflect.Float32:
+		return "float32"
+	case reflect.Float64:
+		return "float64"
+	case reflect.String:
+		return "text"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		} else if of := getStructFieldType(t.Elem()); len(of) > 0 {
+			return "array:" + of
+		}
+	}
+	return ""
+}
+
+// 1:rw;2:ro;3:wo
+func parseStructPermissions(value string) ([]usr.Permission, error) {
+	result := make([]usr.Permission, 0)
+	if len(value) == 0 {
+		return result, nil
+	}
+	for _, item := range strings.Split(value, ";") {
+		p := strings.SplitN(item, ":", 2)
+		if len(p) != 2 {
+			return nil, fmt.Errorf("wrong permission %s", item)
+		}
+		role, err := strconv.ParseUint(strings.TrimSpace(p[0]), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("wrong permission role %s", item)
+		}
+		switch strings.ToLower(strings.TrimSpace(p[1])) {
+		case "ro":
+			result = append(result, usr.P_RO(usr.Role(role)))
+		case "wo":
+			result = append(result, usr.P_WO(usr.Role(role)))
+		case "rw":
+			result = append(result, usr.P_RW(usr.Role(role)))
+		default:
+			return nil, fmt.Errorf("wrong permission access %s", item)
+		}
+	}
+	return result, nil
+}
+
+// exported fields with the grest tag, fields of embedded structs without the tag are promoted (Index is the path)
+func getStructFields(t reflect.Type) []reflect.StructField {
+	result := make([]reflect.StructField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("grest")
+		if sf.Anonymous && !ok {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Ptr && len(sf.PkgPath) == 0 {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() != reflect.Struct {
+				continue
+			}
+			for _, f := range getStructFields(embedded) {
+				f.Index = append([]int{i}, f.Index...)
+				result = append(result, f)
+			}
+			continue
+		}
+		if len(sf.PkgPath) > 0 || !ok || tag == "-" {
+			continue
+		}
+		result = append(result, sf)
+	}
+	return result
+}
+
+func scanStructRow(row map[string]interface{}, dest reflect.Value) error {
+	t := dest.Type()
+	for _, sf := range getStructFields(t) {
+		name := strings.ToLower(sf.Name)
+		if n := strings.Split(sf.Tag.Get("grest"), ",")[0]; len(n) > 0 {
+			name = n
+		}
+		value, ok := row[name]
+		if !ok {
+			continue
+		}
+		// embedded struct pointers are allocated on the way
+		field := dest
+		for n, i := range sf.Index {
+			if n > 0 && field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					field.Set(reflect.New(field.Type().Elem()))
+				}
+				field = field.Elem()
+			}
+			field = field.Field(i)
+		}
+		if err := setStructValue(field, value); err != nil {
+			return fmt.Errorf("%s.%s: %s", t.Name(), sf.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+func setStructValue(dest reflect.Value, value interface{}) error {
+	if value == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+	if dest.Kind() == reflect.Ptr {
+		v := reflect.New(dest.Type().Elem())
+		if err := setStructValue(v.Elem(), value); err != nil {
+			return err
+		}
+		dest.Set(v)
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(dest.Type()) {
+		dest.Set(v)
+		return nil
+	}
+	// text values
+	text := ""
+	switch val := value.(type) {
+	case []byte:
+		text = string(val)
+	case string:
+		text = val
+	case json.Number:
+		text = val.String()
+	case fmt.Stringer:
+		text = val.String()
+	default:
+		if v.Type().ConvertibleTo(dest.Type()) && v.Kind() != reflect.String && dest.Kind() != reflect.String {
+			dest.Set(v.Convert(dest.Type()))
+			return nil
+		}
+		text = fmt.Sprint(value)
+	}
+	if dest.Type() == reflect.TypeOf(time.Time{}) {
+		t := helper.ParseTime(text)
+		if t.IsZero() {
+			return fmt.Errorf("wrong time %s", text)
+		}
+		dest.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch dest.Kind() {
+	case reflect.String:
+		dest.SetString(text)
+	case reflect.Slice:
+		if dest.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("can not convert %T", value)
+		}
+		dest.SetBytes([]byte(text))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		dest.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, dest.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dest.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, dest.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dest.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, dest.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dest.SetFloat(f)
+	default:
+		return fmt.Errorf("can not convert %T", value)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package grest
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/usr"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testStructBase struct {
+	ID int64 `grest:"id,,perm=1:ro"`
+}
+
+// exported, pointers to embedded structs of unexported types can not be allocated
+type StructAudit struct {
+	Updated *time.Time `grest:"updated"`
+}
+
+type testStructUser struct {
+	testStructBase
+	*StructAudit
+	Login  string   `grest:"login,,perm=1:rw;2:ro,required,len=3:32,email,column=user_login"`
+	Email  *string  `grest:"email,text"`
+	Age    int      `grest:",,min=0,max=150,nullable"`
+	Born   string   `grest:"born,date"`
+	State  string   `grest:"state,enum,values=new|done"`
+	Data   []byte   `grest:"data"`
+	Tags   []string `grest:"tags"`
+	Skip   string   `grest:"-"`
+	Hidden string
+	secret string
+}
+
+func TestNewModelFromStruct(t *testing.T) {
+	cases := []struct {
+		name  string
+		value interface{}
+		// name:type:required:nullable:column:rules, empty on error
+		fields []string
+	}{
+		{"tag options", testStructUser{}, []string{
+			"id:int64:false:false::",
+			"updated:datetime:false:true::",
+			"login:text:true:false:user_login:length,email",
+			"email:text:false:true::",
+			"age:int64:false:true::min,max",
+			"born:date:false:false::",
+			"state:enum:false:false::",
+			"data:bytes:false:false::",
+			"tags:array:text:false:false::",
+		}},
+		{"pointer to struct", &testStructBase{}, []string{"id:int64:false:false::"}},
+		{"datetime format", struct {
+			At time.Time `grest:"at,datetime,format=2006-01-02"`
+		}{}, []string{"at:datetime:false:false::"}},
+		{"unknown type", struct {
+			Meta map[string]string `grest:"meta"`
+		}{}, nil},
+		{"wrong permission", struct {
+			ID int64 `grest:"id,,perm=1:rx"`
+		}{}, nil},
+		{"missing enum values", struct {
+			State string `grest:"state,enum"`
+		}{}, nil},
+		{"wrong regex", struct {
+			Code string `grest:"code,,regex=[a-z"`
+		}{}, nil},
+		{"not a struct", 42, nil},
+	}
+	for _, c := range cases {
+		model, err := NewModelFromStruct("users", c.value)
+		if c.fields == nil {
+			if err == nil {
+				t.Errorf("model-struct[%s]: error expected", c.name)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("model-struct[%s]: %s", c.name, err.Error())
+			continue
+		}
+		fields := make([]string, 0)
+		for _, field := range model.Fields() {
+			rules := make([]string, 0)
+			if f, ok := field.(FieldWithRules); ok && f != nil {
+				for _, rule := range f.Rules() {
+					rules = append(rules, rule.Code())
+				}
+			}
+			column := ""
+			if c := getFieldColumn(field); c != field.Name() {
+				column = c
+			}
+			fields = append(fields, fmt.Sprintf("%s:%s:%t:%t:%s:%s", field.Name(), getFieldType(field), getFieldRequired(field), getFieldNullable(field), column, strings.Join(rules, ",")))
+		}
+		if !reflect.DeepEqual(fields, c.fields) {
+			t.Errorf("model-struct[%s]: wrong fields\n%s\nexpected\n%s", c.name, strings.Join(fields, "\n"), strings.Join(c.fields, "\n"))
+		}
+	}
+	// permissions
+	model, _ := NewModelFromStruct("users", testStructUser{})
+	if login := getModelField(model, "login"); !reflect.DeepEqual(login.Roles(usr.ALEVEL_WRITE), usr.Roles{1}) || !reflect.DeepEqual(login.Roles(usr.ALEVEL_READ), usr.Roles{1, 2}) {
+		t.Errorf("model-struct[permissions]: wrong roles %v / %v", login.Roles(usr.ALEVEL_WRITE), login.Roles(usr.ALEVEL_READ))
+	}
+}
+
+func TestScanRows(t *testing.T) {
+	born := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		name     string
+		row      map[string]interface{}
+		expected func(u testStructUser) bool
+	}{
+		{"embedded", map[string]interface{}{"id": int64(7)}, func(u testStructUser) bool {
+			return u.ID == 7 && u.StructAudit == nil
+		}},
+		{"embedded pointer", map[string]interface{}{"updated": born}, func(u testStructUser) bool {
+			return u.StructAudit != nil && u.Updated != nil && u.Updated.Equal(born)
+		}},
+		{"time by text", map[string]interface{}{"updated": []byte("2000-01-02T03:04:05Z")}, func(u testStructUser) bool {
+			return u.StructAudit != nil && u.Updated != nil && u.Updated.Equal(born)
+		}},
+		{"nullable", map[string]interface{}{"email": nil, "age": nil}, func(u testStructUser) bool {
+			return u.Email == nil && u.Age == 0
+		}},
+		{"pointer", map[string]interface{}{"email": "a@b.c"}, func(u testStructUser) bool {
+			return u.Email != nil && *u.Email == "a@b.c"
+		}},
+		{"number by bytes", map[string]interface{}{"age": []byte("42"), "id": int32(3)}, func(u testStructUser) bool {
+			return u.Age == 42 && u.ID == 3
+		}},
+		{"bytes by text", map[string]interface{}{"data": "raw", "login": []byte("demo")}, func(u testStructUser) bool {
+			return string(u.Data) == "raw" && u.Login == "demo"
+		}},
+		{"unknown columns", map[string]interface{}{"unknown": 1, "skip": "x", "hidden": "x", "secret": "x"}, func(u testStructUser) bool {
+			return u.Skip == "" && u.Hidden == "" && u.secret == ""
+		}},
+	}
+	for _, c := range cases {
+		u := testStructUser{}
+		if err := ScanRows([]map[string]interface{}{c.row}, &u); err != nil {
+			t.Errorf("model-struct[%s]: %s", c.name, err.Error())
+		} else if !c.expected(u) {
+			t.Errorf("model-struct[%s]: wrong value %+v", c.name, u)
+		}
+	}
+	// slice of pointers, conversion errors
+	users := make([]*testStructBase, 0)
+	if err := ScanRows([]map[string]interface{}{{"id": int64(1)}, {"id": "2"}}, &users); err != nil {
+		t.Fatal(err)
+	} else if len(users) != 2 || users[0].ID != 1 || users[1].ID != 2 {
+		t.Errorf("model-struct[slice]: wrong values %v", users)
+	}
+	if err := ScanRows([]map[string]interface{}{{"id": "x"}}, &users); err == nil {
+		t.Errorf("model-struct[conversion]: error expected")
+	}
+	if err := ScanRows([]map[string]interface{}{{"id": 1}}, testStructBase{}); err == nil {
+		t.Errorf("model-struct[destination]: error expected")
+	}
+}
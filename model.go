@@ -1,6 +1,8 @@
 package grest
 
 import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
 	"github.com/prorochestvo/grest/internal"
 	"github.com/prorochestvo/grest/usr"
 )
@@ -55,6 +57,66 @@ func getModelField(model Model, name string) Field {
 	return result
 }
 
+// escaped column (or expression) of the model field by name, escaped name if not a model field
+func getModelColumn(driver db.Driver, model Model, name string) string {
+	if model != nil {
+		if field := getModelField(model, name); field != nil {
+			if expression := getFieldExpression(field); len(expression) > 0 {
+				return fmt.Sprintf("(%s)", expression)
+			}
+			return driver.Escape(getFieldColumn(field))
+		}
+	}
+	return driver.Escape(name)
+}
+
+func getFieldColumn(field Field) string {
+	if f, ok := field.(FieldWithColumn); ok && f != nil {
+		if column := f.Column(); len(column) > 0 {
+			return column
+		}
+	}
+	return field.Name()
+}
+
+func getFieldExpression(field Field) string {
+	if f, ok := field.(FieldWithColumn); ok && f != nil {
+		return f.Expression()
+	}
+	return ""
+}
+
+func getFieldType(field Field) string {
+	if f, ok := field.(FieldWithType); ok && f != nil {
+		return f.Type()
+	}
+	return ""
+}
+
+func getFieldRequired(field Field) bool {
+	if f, ok := field.(FieldWithOptions); ok && f != nil {
+		return f.Required()
+	}
+	return false
+}
+
+func getFieldNullable(field Field) bool {
+	if f, ok := field.(FieldWithOptions); ok && f != nil {
+		return f.Nullable()
+	}
+	return false
+}
+
+// column AS name
+func getFieldSelect(driver db.Driver, field Field) db.SQLField {
+	if expression := getFieldExpression(field); len(expression) > 0 {
+		return db.NewSQLField(fmt.Sprintf("(%s) AS %s", expression, driver.Escape(field.Name())), nil)
+	} else if column := getFieldColumn(field); column != field.Name() {
+		return db.NewSQLField(fmt.Sprintf("%s AS %s", driver.Escape(column), driver.Escape(field.Name())), nil)
+	}
+	return db.NewSQLField(driver.Escape(field.Name()), nil)
+}
+
 func getModelFields(model Model, role usr.Role, level ...internal.AccessLevel) map[string]Field {
 	result := make(map[string]Field, 0)
 	for _, field := range model.Fields() {
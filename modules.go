@@ -6,6 +6,7 @@ import (
 	"github.com/prorochestvo/grest/db"
 	"github.com/prorochestvo/grest/internal/helper"
 	"github.com/prorochestvo/grest/usr"
+	"html"
 	"io/ioutil"
 	"net/http"
 	"reflect"
@@ -214,7 +215,7 @@ func (this *ModuleSqlEditor) Roles() usr.Roles {
 
 func (this *ModuleSqlEditor) Run(r *Request) (int, map[string]string, interface{}, error) {
 	where, groupBy, orderBy, having, limit, offset := db.SQLParser(r.Request.URL.Query())
-	body := db.NewSQLLinker().Select(db.NewSQLTable(string(r.URL.ID.Value)), []db.SQLField{db.NewSQLField("*", nil)}, where, groupBy, having, orderBy, limit, offset)
+	body := db.NewSQLLinker(db.GetDialect(r.DB)).Select(db.NewSQLTable(string(r.URL.ID.Value)), []db.SQLField{db.NewSQLField("*", nil)}, where, groupBy, having, orderBy, limit, offset)
 	head := map[string]string{
 		"Content-Type": "text/plain; charset=utf-8",
 	}
@@ -301,3 +302,130 @@ func (this *ModuleHttpParser) Run(r *Request) (int, map[string]string, interface
 	}
 	return http.StatusOK, head, body, nil
 }
+
+/***********************************************************************************************************************
+ * ModuleMigrationStatus
+ * Состояние миграций: текущая версия, примененные, ожидающие и расхождения схемы (JSON, HTML по Accept: text/html)
+ */
+type ModuleMigrationStatus struct {
+	path string
+	Role []usr.Role
+	CSS  []string
+}
+
+func (this *ModuleMigrationStatus) WithID() bool {
+	return false
+}
+
+func (this *ModuleMigrationStatus) Methods() []string {
+	return []string{
+		http.MethodGet,
+		http.MethodHead,
+	}
+}
+
+func (this *ModuleMigrationStatus) Path() string {
+	if len(this.path) > 0 {
+		return this.path
+	}
+	return "migration/status"
+}
+
+func (this *ModuleMigrationStatus) SetPath(value string) {
+	this.path = value
+}
+
+func (this *ModuleMigrationStatus) Id() (name, pattern string) {
+	return "id", "[0-9]+"
+}
+
+func (this *ModuleMigrationStatus) Roles() usr.Roles {
+	if this.Role == nil {
+		return make([]usr.Role, 0)
+	}
+	return this.Role
+}
+
+func (this *ModuleMigrationStatus) Run(r *Request) (int, map[string]string, interface{}, error) {
+	// deny by default, versions and schema findings are not public
+	if len(this.Role) == 0 {
+		return http.StatusForbidden, nil, nil, fmt.Errorf("don't have permission")
+	}
+	// response
+	type inside struct {
+		Version string            `json:"version"`
+		Applied []MigrationStatus `json:"applied"`
+		Pending []MigrationStatus `json:"pending"`
+		Revise  []ReviseIssue     `json:"revise"`
+	}
+	response := inside{
+		Version: r.router.Migration.Version(),
+		Applied: make([]MigrationStatus, 0),
+		Pending: make([]MigrationStatus, 0),
+		Revise:  make([]ReviseIssue, 0),
+	}
+	if status, err := r.router.Migration.Status(); err != nil {
+		return http.StatusInternalServerError, nil, nil, err
+	} else {
+		for _, s := range status {
+			if s.Applied {
+				response.Applied = append(response.Applied, s)
+			} else {
+				response.Pending = append(response.Pending, s)
+			}
+		}
+	}
+	if report, err := r.router.Migration.Report(); err != nil {
+		return http.StatusInternalServerError, nil, nil, err
+	} else if report != nil && !report.Empty() {
+		response.Revise = report.Issues
+	}
+	// html
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		applied := make([]string, 0, len(response.Applied))
+		for _, s := range response.Applied {
+			mark := "✔"
+			if s.Changed {
+				mark = "⚠ changed"
+			} else if s.Orphan {
+				mark = "? source not found"
+			}
+			at := ""
+			if s.AppliedAt != nil {
+				at = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			applied = append(applied, fmt.Sprintf("<li>%s <span class=\"text-monospaced\">%s</span> <i class=\"m-l-xs small\">%s</i></li>", mark, html.EscapeString(s.Version), at))
+		}
+		pending := make([]string, 0, len(response.Pending))
+		for _, s := range response.Pending {
+			pending = append(pending, fmt.Sprintf("<li>✘ <span class=\"text-monospaced\">%s</span></li>", html.EscapeString(s.Version)))
+		}
+		revise := make([]string, 0, len(response.Revise))
+		for _, issue := range response.Revise {
+			revise = append(revise, fmt.Sprintf("<li>%s <i class=\"m-l-xs small\">%s</i></li>", html.EscapeString(issue.Message), issue.Code))
+		}
+		head := map[string]string{
+			"Content-Type": "text/html; charset=utf-8",
+		}
+		body := fmt.Sprintf(`
+    <h3 style="margin-bottom: 5px">
+      Migrations %s
+    </h3>
+    <hr class="m-b-md m-t-xs">
+    <div class="m-b-sm m-t-sm"><span class="font-bold">Applied</span><ul style="padding-left: 10px">%s</ul></div>
+    <div class="m-b-sm m-t-sm"><span class="font-bold">Pending</span><ul style="padding-left: 10px">%s</ul></div>
+    <div class="m-b-sm m-t-sm"><span class="font-bold">Revise</span><ul style="padding-left: 10px">%s</ul></div>`, html.EscapeString(response.Version), strings.Join(applied, "\n"), strings.Join(pending, "\n"), strings.Join(revise, "\n"))
+		return http.StatusOK, head, helper.HtmlDocument(body, this.CSS...), nil
+	}
+	// json
+	body := make([]byte, 0)
+	if b, err := json.MarshalIndent(response, "", "  "); err != nil {
+		return http.StatusInternalServerError, nil, nil, err
+	} else {
+		body = b
+	}
+	head := map[string]string{
+		"Content-Type": "application/json",
+	}
+	return http.StatusOK, head, body, nil
+}
@@ -0,0 +1,61 @@
+package grest
+
+import (
+	"encoding/json"
+	"github.com/prorochestvo/grest/db"
+	"github.com/prorochestvo/grest/usr"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestModuleMigrationStatus(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	module := &ModuleMigrationStatus{Role: []usr.Role{usr.DefaultRole}}
+	router := newTestRouter(driver, &testController{path: "admin", model: newTestModel(), actions: []Action{module}})
+	router.Migration.Append(db.NewMigration("0001_users", "CREATE TABLE users (id BIGINT);", "DROP TABLE users;"))
+	status := func() (applied, pending []string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/migration/status", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("module[migration-status]: wrong status %d, body %s", w.Code, w.Body.String())
+		}
+		response := struct {
+			Applied []MigrationStatus `json:"applied"`
+			Pending []MigrationStatus `json:"pending"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range response.Applied {
+			applied = append(applied, s.Version)
+		}
+		for _, s := range response.Pending {
+			pending = append(pending, s.Version)
+		}
+		return applied, pending
+	}
+	// history tables do not exist, nothing applied and no DDL
+	if applied, pending := status(); len(applied) != 0 || strings.Join(pending, ",") != "0001_users" {
+		t.Errorf("module[migration-status]: wrong applied %v or pending %v", applied, pending)
+	} else if len(driver.executed) != 0 {
+		t.Errorf("module[migration-status]: unexpected queries\n%s", strings.Join(driver.executed, "\n"))
+	}
+	if err := router.Migration.Up(); err != nil {
+		t.Fatal(err)
+	}
+	executed := len(driver.executed)
+	if applied, pending := status(); strings.Join(applied, ",") != "0001_users" || len(pending) != 0 {
+		t.Errorf("module[migration-status]: wrong applied %v or pending %v", applied, pending)
+	} else if len(driver.executed) != executed {
+		t.Errorf("module[migration-status]: unexpected queries\n%s", strings.Join(driver.executed[executed:], "\n"))
+	}
+	// deny by default
+	module.Role = nil
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/migration/status", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("module[migration-status]: wrong status %d without roles", w.Code)
+	}
+}
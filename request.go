@@ -1,6 +1,8 @@
 package grest
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/prorochestvo/grest/db"
 	"github.com/prorochestvo/grest/internal"
@@ -8,6 +10,7 @@ import (
 	"github.com/prorochestvo/grest/internal/logger"
 	"github.com/prorochestvo/grest/internal/mux"
 	"github.com/prorochestvo/grest/usr"
+	"io"
 	"io/ioutil"
 	"time"
 )
@@ -26,18 +29,39 @@ type Request struct {
 	*mux.Request
 }
 
+// escaped column (or expression) of the model field by name
+func (this *Request) column(name string) string {
+	return getModelColumn(this.DB, this.Model, name)
+}
+
 func (this *Request) Logger(format string, arg ...interface{}) {
 	if err := logger.FileSavef(format, arg...); err != nil {
 		_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("logger: %s, %s", time.Now().Format("2006-01-02"), err.Error())))
 	}
 }
 
-func (this *Request) body() (map[string]interface{}, internal.Error) {
+// request body, JSON numbers are decoded as json.Number (without float64 rounding, see FieldWithCast)
+func (this *Request) unmarshal(data []byte, v interface{}) error {
+	if this.router.ContentType.Format != internal.JSON.Format {
+		return this.router.ContentType.Unmarshal(data, v)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	} else if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid data after top-level value")
+	}
+	return nil
+}
+
+// create: check required fields
+func (this *Request) body(create bool) (map[string]interface{}, internal.Error) {
 	var result map[string]interface{} = nil
 	var tmp interface{} = nil
 	if b, err := ioutil.ReadAll(this.Body); this.Body != nil && err != nil {
 		return nil, internal.NewError(internal.StatusBadRequest, err.Error())
-	} else if err := this.router.ContentType.Unmarshal(b, &tmp); err != nil {
+	} else if err := this.unmarshal(b, &tmp); err != nil {
 		return nil, internal.NewError(internal.StatusBadRequest, err.Error())
 	} else if data, ok := tmp.(map[string]interface{}); !ok || data == nil || len(data) == 0 {
 		return nil, internal.NewError(internal.StatusBadRequest, "wrong request body")
@@ -45,16 +69,131 @@ func (this *Request) body() (map[string]interface{}, internal.Error) {
 		return nil, internal.NewError(internal.StatusForbidden, "fields not found by %s", helper.TypeName(this.Model))
 	} else {
 		result = make(map[string]interface{}, 0)
+		report := &ValidationError{}
 		for name, value := range data {
-			if field, ok := fields[name]; !ok || !field.Validate(value) {
-				return nil, internal.NewError(internal.StatusUnprocessableEntity, "wrong field %s", name)
+			field, ok := fields[name]
+			if !ok {
+				report.Append(name, "forbidden", "field is not writable")
+				continue
+			} else if len(getFieldExpression(field)) > 0 {
+				report.Append(name, "forbidden", "field is read-only")
+				continue
+			}
+			if value == nil {
+				if f, ok := field.(FieldWithOptions); !ok || f == nil || !f.Nullable() {
+					report.Append(name, "null", "value can not be null")
+				} else {
+					result[name] = nil
+				}
+				continue
+			}
+			if f, ok := field.(FieldWithCast); ok && f != nil {
+				v, err := f.Cast(value)
+				if err != nil {
+					report.Append(name, "type", err.Error())
+					continue
+				}
+				value = v
+			}
+			if !field.Validate(value) {
+				report.Append(name, "invalid", "wrong value")
+			}
+			if f, ok := field.(FieldWithRules); ok && f != nil {
+				for _, rule := range f.Rules() {
+					if rule != nil && !rule.Check(value) {
+						report.Append(name, rule.Code(), rule.Message())
+					}
+				}
 			}
 			result[name] = value
 		}
+		if create {
+			values := getScopeValues(this)
+			for name, field := range fields {
+				if f, ok := field.(FieldWithOptions); !ok || f == nil || !f.Required() {
+					continue
+				} else if f, ok := field.(FieldWithSource); ok && f != nil && f.OnCreate() != nil {
+					continue
+				} else if _, ok := values[name]; ok {
+					continue
+				} else if _, ok := data[name]; !ok {
+					report.Append(name, "required", "field is required")
+				}
+			}
+		}
+		if !report.Empty() {
+			report.sort()
+			return nil, report
+		}
 	}
 	return result, nil
 }
 
+// apply server side values of the model fields
+func (this *Request) source(data map[string]interface{}, create bool) internal.Error {
+	for _, f := range this.Model.Fields() {
+		field, ok := f.(FieldWithSource)
+		if !ok || field == nil {
+			continue
+		}
+		source := field.OnUpdate()
+		if create {
+			source = field.OnCreate()
+		}
+		if source == nil {
+			continue
+		}
+		value, err := source(this)
+		if err != nil {
+			return internal.NewError(internal.StatusInternalServerError, "field %s: %s", field.Name(), err.Error())
+		}
+		data[field.Name()] = value
+	}
+	return nil
+}
+
+// time zone of response values by "X-Timezone" header (IANA name), nil if not set
+func (this *Request) Location() *time.Location {
+	name := this.Header.Get("X-Timezone")
+	if len(name) == 0 {
+		return nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// response values of the model fields
+func (this *Request) format(model Model, data interface{}) interface{} {
+	formatters := make(map[string]FieldWithFormat, 0)
+	for _, f := range model.Fields() {
+		if field, ok := f.(FieldWithFormat); ok && field != nil {
+			formatters[field.Name()] = field
+		}
+	}
+	if len(formatters) == 0 {
+		return data
+	}
+	location := this.Location()
+	apply := func(item map[string]interface{}) {
+		for name, field := range formatters {
+			if value, ok := item[name]; ok && value != nil {
+				item[name] = field.Format(value, location)
+			}
+		}
+	}
+	if items, ok := data.([]map[string]interface{}); ok && items != nil {
+		for _, item := range items {
+			apply(item)
+		}
+	} else if item, ok := data.(map[string]interface{}); ok && item != nil {
+		apply(item)
+	}
+	return data
+}
+
 func (this *Request) expand(model Model, data interface{}) interface{} {
 	const interimKeyName string = "tmp_key_a7271a8b5f3b9ca7d5cb65d07a8f50f6"
 	type Binding interface {
@@ -106,18 +245,21 @@ func (this *Request) expand(model Model, data interface{}) interface{} {
 			table := db.NewSQLTable(field.ExternalModel().Table())
 			where := make([]db.SQLWhere, 0)
 			for i, externalKey := range field.ExternalKeys() {
-				where = append(where, db.NewSQLWhere(externalKey.Name(), internalValues[i], "in"))
-				fields = append(fields, db.NewSQLField(fmt.Sprintf("%s as %s_%d", externalKey.Name(), interimKeyName, i), nil))
+				column := getModelColumn(this.DB, field.ExternalModel(), externalKey.Name())
+				where = append(where, db.NewSQLWhere(column, internalValues[i], "in"))
+				fields = append(fields, db.NewSQLField(fmt.Sprintf("%s as %s_%d", column, interimKeyName, i), nil))
 			}
 			for _, field := range f {
-				fields = append(fields, db.NewSQLField(field.Name(), nil))
+				fields = append(fields, getFieldSelect(this.DB, field))
 			}
 			var limit db.SQLLimit = nil
 			if l := field.Limit(); l >= 0 {
 				limit = db.NewSQLLimit(l)
 			}
 			if res, err := this.DB.Select(table, fields, where, nil, nil, nil, limit, nil); err == nil && res != nil {
-				return res
+				// рекурсия для всех под модулей (once per row, rows may be shared by several internal values)
+				res = this.expand(field.ExternalModel(), res).([]map[string]interface{})
+				return this.format(field.ExternalModel(), res).([]map[string]interface{})
 			}
 		}
 		return make([]map[string]interface{}, 0)
@@ -144,10 +286,6 @@ func (this *Request) expand(model Model, data interface{}) interface{} {
 				result = append(result, value)
 			}
 		}
-		if len(result) > 0 {
-			// рекурсия для всех под модулей
-			result = this.expand(field.ExternalModel(), result).([]map[string]interface{})
-		}
 		if field.Limit() == 1 {
 			if len(result) > 0 {
 				return result[0]
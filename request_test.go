@@ -0,0 +1,174 @@
+package grest
+
+import (
+	"encoding/json"
+	"github.com/prorochestvo/grest/db"
+	"github.com/prorochestvo/grest/usr"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestValidation(t *testing.T) {
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: newTestValidationModel(), actions: []Action{NewActionCreate(), NewActionUpdate()}})
+	driver.rows = []map[string]interface{}{{"id": int64(1), "login": "demo", "age": int64(30)}}
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		// field:code in order, empty if valid
+		expected string
+	}{
+		{"forbidden", http.MethodPost, `{"login":"demo","id":5}`, "id:forbidden"},
+		{"read-only", http.MethodPost, `{"login":"demo","total":1}`, "total:forbidden"},
+		{"null", http.MethodPost, `{"login":"demo","age":null}`, "age:null"},
+		{"nullable", http.MethodPost, `{"login":"demo","note":null}`, ""},
+		{"type", http.MethodPost, `{"login":"demo","age":"x"}`, "age:type"},
+		{"rule", http.MethodPost, `{"login":"ab"}`, "login:length"},
+		{"required", http.MethodPost, `{"age":1}`, "login:required"},
+		{"several", http.MethodPost, `{"id":1,"login":"ab","age":[]}`, "age:type,id:forbidden,login:length"},
+		{"valid", http.MethodPost, `{"login":"demo","age":30}`, ""},
+		{"required on update", http.MethodPut, `{"age":31}`, ""},
+		{"rule on update", http.MethodPut, `{"login":"a"}`, "login:length"},
+	}
+	for _, c := range cases {
+		path := "/users"
+		if c.method == http.MethodPut {
+			path = "/users/1"
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(c.method, path, strings.NewReader(c.body)))
+		if len(c.expected) == 0 {
+			if w.Code != http.StatusCreated && w.Code != http.StatusAccepted {
+				t.Errorf("request[validation:%s]: wrong status %d, body %s", c.name, w.Code, w.Body.String())
+			}
+			continue
+		} else if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("request[validation:%s]: wrong status %d, body %s", c.name, w.Code, w.Body.String())
+			continue
+		}
+		response := struct {
+			Fields []FieldError `json:"fields"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("request[validation:%s]: %s", c.name, err.Error())
+			continue
+		}
+		fields := make([]string, 0, len(response.Fields))
+		for _, f := range response.Fields {
+			if len(f.Message) == 0 {
+				t.Errorf("request[validation:%s]: missing message of %s", c.name, f.Field)
+			}
+			fields = append(fields, f.Field+":"+f.Code)
+		}
+		if result := strings.Join(fields, ","); result != c.expected {
+			t.Errorf("request[validation:%s]: wrong fields «%s», must be «%s»", c.name, result, c.expected)
+		}
+	}
+}
+
+func TestRequestNumbers(t *testing.T) {
+	rw := usr.P_RW(usr.DefaultRole)
+	parser := func(value string) (interface{}, error) { return strconv.ParseInt(value, 10, 64) }
+	model := NewModel("users", []Field{INT64("id", usr.P_RO(usr.DefaultRole)), INT64("big", rw), FIELD("plain", parser, rw), FIELD("raw", nil, rw), FIELD("meta", nil, rw)})
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: model, actions: []Action{NewActionCreate(), NewActionUpdate()}})
+	driver.rows = []map[string]interface{}{{"id": int64(1)}}
+	// above 2^53, float64 rounds it to 9007199254740992
+	body := `{"big":9007199254740993,"plain":9007199254740993,"raw":9007199254740993,"meta":{"n":9007199254740993}}`
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, map[string]string{http.MethodPost: "/users", http.MethodPut: "/users/1"}[method], strings.NewReader(body)))
+		if w.Code != http.StatusCreated && w.Code != http.StatusAccepted {
+			t.Errorf("request[numbers:%s]: wrong status %d, body %s", method, w.Code, w.Body.String())
+			continue
+		}
+		for _, name := range []string{"big", "plain", "raw"} {
+			if v, ok := driver.written[name].(int64); !ok || v != 9007199254740993 {
+				t.Errorf("request[numbers:%s]: wrong %s %v (%T)", method, name, driver.written[name], driver.written[name])
+			}
+		}
+		if meta, ok := driver.written["meta"].(map[string]interface{}); !ok || meta["n"] != json.Number("9007199254740993") {
+			t.Errorf("request[numbers:%s]: wrong meta %v", method, driver.written["meta"])
+		}
+	}
+}
+
+func TestRequestSource(t *testing.T) {
+	rw := usr.P_RW(usr.DefaultRole)
+	createdBy := INT64("created_by", rw)
+	createdBy.SetOnCreate(SourceUserID())
+	createdAt := DATETIME("created_at", time.RFC3339, rw)
+	createdAt.SetOnCreate(SourceNow())
+	updatedAt := DATETIME("updated_at", time.RFC3339, rw)
+	updatedAt.SetOnUpdate(SourceNow())
+	state := TEXT("state", rw)
+	state.SetOnCreate(SourceConst("new"))
+	state.SetOnUpdate(SourceConst("edited"))
+	model := NewModel("users", []Field{INT64("id", usr.P_RO(usr.DefaultRole)), TEXT("login", rw), createdBy, createdAt, updatedAt, state})
+	driver := newTestDriver(db.DialectPostgreSQL)
+	router := newTestRouter(driver, &testController{path: "users", model: model, actions: []Action{NewActionCreate(), NewActionUpdate()}})
+	router.AccessControl.User = func(_ *Request) (usr.User, error) {
+		return usr.NewUser(int64(7), usr.DefaultRole), nil
+	}
+	driver.rows = []map[string]interface{}{{"id": int64(1)}}
+	past := "2000-01-01T00:00:00Z"
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected map[string]interface{} // written values, time.Time for now
+		missing  []string
+	}{
+		{"create", http.MethodPost, "/users", `{"login":"demo","created_by":9,"created_at":"` + past + `","state":"hacked"}`,
+			map[string]interface{}{"login": "demo", "created_by": int64(7), "created_at": time.Time{}, "state": "new"}, []string{"updated_at"}},
+		{"update", http.MethodPut, "/users/1", `{"login":"other","updated_at":"` + past + `","state":"hacked"}`,
+			map[string]interface{}{"login": "other", "updated_at": time.Time{}, "state": "edited"}, []string{"created_by", "created_at"}},
+	}
+	for _, c := range cases {
+		start := time.Now().UTC()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if w.Code != http.StatusCreated && w.Code != http.StatusAccepted {
+			t.Errorf("request[source:%s]: wrong status %d, body %s", c.name, w.Code, w.Body.String())
+			continue
+		}
+		for name, expected := range c.expected {
+			value := driver.written[name]
+			if _, ok := expected.(time.Time); ok {
+				if v, ok := value.(time.Time); !ok || v.Before(start) {
+					t.Errorf("request[source:%s]: wrong %s %v, must be now", c.name, name, value)
+				}
+			} else if value != expected {
+				t.Errorf("request[source:%s]: wrong %s %v, must be %v", c.name, name, value, expected)
+			}
+		}
+		for _, name := range c.missing {
+			if value, ok := driver.written[name]; ok {
+				t.Errorf("request[source:%s]: unexpected %s %v", c.name, name, value)
+			}
+		}
+	}
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+// id is read-only by permission, total by expression
+func newTestValidationModel() Model {
+	rw := usr.P_RW(usr.DefaultRole)
+	login := TEXT("login", rw)
+	login.SetRequired(true)
+	login.SetRules(RuleLength(3, 8))
+	note := TEXT("note", rw)
+	note.SetNullable(true)
+	total := INT64("total", rw)
+	total.SetExpression("(SELECT 1)")
+	return NewModel("users", []Field{INT64("id", usr.P_RO(usr.DefaultRole)), login, INT64("age", rw), note, total})
+}
@@ -1,11 +1,13 @@
 package grest
 
 import (
+	"crypto/sha1"
 	"fmt"
 	"github.com/prorochestvo/grest/db"
 	"github.com/prorochestvo/grest/internal/mux"
 	"net/http"
 	"strings"
+	"time"
 )
 
 func newRoute(router *Router, driver db.Driver, controller Controller, action Action) *route {
@@ -42,19 +44,32 @@ func (this *route) run(w http.ResponseWriter, r *mux.Request) {
 	defer func() {
 		_ = r.Body.Close()
 	}()
-	var err error
-	var code = http.StatusInternalServerError
-	var head map[string]string = nil
-	var body interface{} = nil
 	req := newRequest(r, this)
-	if req.User, err = this.router.AccessControl.User(req); err != nil {
-		code, head, body = this.router.AccessControl.Error(req, http.StatusUnauthorized, head, err)
-	} else if roles := this.action.Roles(); roles != nil && len(roles) > 0 && roles.IndexOf(req.User.Role()) < 0 {
-		code, head, body = this.router.AccessControl.Error(req, http.StatusForbidden, head, fmt.Errorf("don't have permission"))
-	} else if code, head, body, err = this.action.Run(req); err != nil {
+	// router -> controller -> action -> handle
+	middleware := make([]Middleware, 0, len(this.router.middleware))
+	middleware = append(middleware, this.router.middleware...)
+	middleware = append(middleware, getControllerMiddleware(this.Controller)...)
+	middleware = append(middleware, getActionMiddleware(this.action)...)
+	handler := getMiddlewareHandler(this.handle, middleware)
+	code, head, body, err := handler(req)
+	if err != nil {
 		code, head, body = this.router.AccessControl.Error(req, code, head, err)
 	}
-	_, _ = this.send(w, code, head, body)
+	_, _ = this.send(w, req, code, head, body)
+}
+
+// user, roles and action, wrapped by middleware; the user set by middleware is kept
+func (this *route) handle(r *Request) (int, map[string]string, interface{}, error) {
+	var err error
+	if r.User == nil {
+		if r.User, err = this.router.AccessControl.User(r); err != nil {
+			return http.StatusUnauthorized, nil, nil, err
+		}
+	}
+	if roles := this.action.Roles(); roles != nil && len(roles) > 0 && roles.IndexOf(r.User.Role()) < 0 {
+		return http.StatusForbidden, nil, nil, fmt.Errorf("don't have permission")
+	}
+	return this.action.Run(r)
 }
 
 func (this *route) cors(w http.ResponseWriter, r *mux.Request) {
@@ -71,10 +86,10 @@ func (this *route) cors(w http.ResponseWriter, r *mux.Request) {
 	} else if code, head, body, err = this.router.AccessControl.Origin(req); err != nil {
 		code, head, body = this.router.AccessControl.Error(req, http.StatusInternalServerError, head, err)
 	}
-	_, _ = this.send(w, code, head, body)
+	_, _ = this.send(w, req, code, head, body)
 }
 
-func (this *route) send(w http.ResponseWriter, code int, head map[string]string, body interface{}) (int, error) {
+func (this *route) send(w http.ResponseWriter, r *Request, code int, head map[string]string, body interface{}) (int, error) {
 	var data []byte = nil
 	if head == nil {
 		head = make(map[string]string, 0)
@@ -93,6 +108,23 @@ func (this *route) send(w http.ResponseWriter, code int, head map[string]string,
 	} else if d, ok := body.([]byte); ok && d != nil && len(d) > 0 {
 		data = d
 	}
+	// conditional request
+	if r != nil && code == http.StatusOK && getActionCached(this.action) && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		if _, ok := head["ETag"]; !ok && len(data) > 0 {
+			head["ETag"] = fmt.Sprintf(`"%x"`, sha1.Sum(data))
+		}
+		// the body depends on the time zone of the request (see Request.Location)
+		if vary, ok := head["Vary"]; !ok || len(vary) == 0 {
+			head["Vary"] = "X-Timezone"
+		} else if !strings.Contains(strings.ToLower(vary), "x-timezone") {
+			head["Vary"] = vary + ", X-Timezone"
+		}
+		if isNotModified(r, head) {
+			code = http.StatusNotModified
+			data = nil
+			delete(head, "Content-Type")
+		}
+	}
 	if data == nil {
 		data = make([]byte, 0)
 	}
@@ -107,3 +139,40 @@ func (this *route) send(w http.ResponseWriter, code int, head map[string]string,
 	w.WriteHeader(code)
 	return w.Write(data)
 }
+
+/***********************************************************************************************************************
+ * helper
+ */
+func isNotModified(r *Request, head map[string]string) bool {
+	// If-None-Match takes precedence over If-Modified-Since (RFC 7232)
+	if match := r.Header.Get("If-None-Match"); len(match) > 0 {
+		etag, ok := head["ETag"]
+		if !ok || len(etag) == 0 {
+			return false
+		}
+		etag = strings.TrimPrefix(etag, "W/")
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.Trim(tag, " \t")
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if since := r.Header.Get("If-Modified-Since"); len(since) > 0 {
+		modified, ok := head["Last-Modified"]
+		if !ok || len(modified) == 0 {
+			return false
+		}
+		s, err := http.ParseTime(since)
+		if err != nil {
+			return false
+		}
+		m, err := http.ParseTime(modified)
+		if err != nil {
+			return false
+		}
+		return !m.Truncate(time.Second).After(s)
+	}
+	return false
+}
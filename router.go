@@ -16,6 +16,7 @@ func newRouter(db db.Driver) *Router {
 	result := &Router{controllers: make([]Controller, 0), Stdout: os.Stdout, Stderr: os.Stderr}
 	result.Version = ""
 	result.Migration = NewMigration(db, result)
+	result.Seed = NewSeeder(db, result)
 	result.AccessControl.User = func(_ *Request) (usr.User, error) {
 		return usr.DefaultUser, nil
 	}
@@ -34,10 +35,14 @@ func newRouter(db db.Driver) *Router {
 	}
 	result.AccessControl.Error = func(_ *Request, code int, head map[string]string, body error) (int, map[string]string, interface{}) {
 		b := struct {
-			Error string `json:"error"`
+			Error  string       `json:"error"`
+			Fields []FieldError `json:"fields,omitempty"`
 		}{
 			Error: body.Error(),
 		}
+		if v, ok := body.(*ValidationError); ok && v != nil {
+			b.Fields = v.Fields
+		}
 		return code, head, b
 	}
 	return result
@@ -58,14 +63,25 @@ func NewXMLRouter(db db.Driver) *Router {
 type Router struct {
 	Version       string
 	Migration     *migration
+	Seed          *seeder
 	controllers   []Controller
 	ContentType   internal.MimeType
 	AccessControl accessControl
 	Stderr        io.Writer
 	Stdout        io.Writer
+	middleware    []Middleware
 	http.Handler
 }
 
+// wrap every action, the first registered middleware is the outermost
+func (this *Router) Use(middleware ...Middleware) {
+	for _, m := range middleware {
+		if m != nil {
+			this.middleware = append(this.middleware, m)
+		}
+	}
+}
+
 func (this *Router) Listen(value ...Controller) error {
 	items := this.controllers
 	for _, v := range value {
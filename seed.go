@@ -0,0 +1,149 @@
+package grest
+
+import (
+	"fmt"
+	"github.com/prorochestvo/grest/db"
+	"github.com/prorochestvo/grest/internal/helper"
+	"sort"
+	"strings"
+)
+
+func NewSeeder(driver db.Driver, router *Router) *seeder {
+	result := seeder{}
+	result.router = router
+	result.driver = driver
+	return &result
+}
+
+type seeder struct {
+	driver  db.Driver
+	router  *Router
+	sources []db.Seed
+}
+
+/* merge seeds from other sources (e.g. db.NewSeedsFromDir) with controller seeds */
+func (this *seeder) Append(seeds ...db.Seed) {
+	this.sources = append(this.sources, seeds...)
+}
+
+/* upsert seeds without tags and seeds with any of the given tags (environments), controller seeds first */
+func (this *seeder) Run(tags ...string) error {
+	result := make([]string, 0)
+	for _, seed := range this.seeds() {
+		if !isSeedTagged(seed, tags) {
+			continue
+		}
+		if inserted, updated, err := this.upsert(seed); err != nil {
+			if this.router.Stderr != nil {
+				_, _ = this.router.Stderr.Write([]byte(fmt.Sprintf("%s\n%s\n", seed.Table(), err.Error())))
+			}
+			if this.router.Stdout != nil {
+				_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("⚠ %s\n\t%s\n", seed.Table(), strings.ReplaceAll(err.Error(), "\n", " "))))
+			}
+			result = append(result, err.Error())
+		} else if this.router.Stdout != nil {
+			_, _ = this.router.Stdout.Write([]byte(fmt.Sprintf("✔ %s\t%d inserted, %d updated\n", seed.Table(), inserted, updated)))
+		}
+	}
+	if len(result) > 0 {
+		return fmt.Errorf(strings.Join(result, "\n"))
+	}
+	return nil
+}
+
+func (this *seeder) seeds() []db.Seed {
+	result := make([]db.Seed, 0)
+	for _, controller := range this.router.controllers {
+		if controller == nil {
+			continue
+		}
+		if c, ok := controller.(ControllerWithSeeds); ok && c != nil {
+			result = append(result, c.Seeds()...)
+		}
+	}
+	return append(result, this.sources...)
+}
+
+/* update rows found by key columns, insert the others, in one transaction if the driver supports it (see db.DriverWithTx) */
+func (this *seeder) upsert(seed db.Seed) (inserted, updated int, err error) {
+	d, ok := this.driver.(db.DriverWithTx)
+	if !ok || d == nil {
+		return this.rows(this.driver, seed)
+	}
+	tx, err := d.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	if inserted, updated, err = this.rows(tx, seed); err != nil {
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+	return inserted, updated, tx.Commit()
+}
+
+func (this *seeder) rows(driver db.Driver, seed db.Seed) (inserted, updated int, err error) {
+	table := db.NewSQLTable(seed.Table())
+	key := seed.Key()
+	if len(key) == 0 {
+		return 0, 0, fmt.Errorf("seed \"%s\" has no key", seed.Table())
+	}
+	for _, row := range seed.Rows() {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		where := make([]db.SQLWhere, 0, len(key))
+		for _, column := range key {
+			value, ok := row[column]
+			if !ok {
+				return inserted, updated, fmt.Errorf("seed \"%s\": key column \"%s\" is missing", seed.Table(), column)
+			}
+			where = append(where, db.NewSQLWhere(driver.Escape(column), value))
+		}
+		rows, err := driver.Select(table, []db.SQLField{db.NewSQLField(driver.Escape(key[0]), nil)}, where, nil, nil, nil, db.NewSQLLimit(1), nil)
+		if err != nil {
+			return inserted, updated, err
+		}
+		if len(rows) > 0 {
+			fields := make([]db.SQLField, 0, len(columns))
+			for _, column := range columns {
+				if helper.StringsIndexOf(key, column) < 0 {
+					fields = append(fields, db.NewSQLField(driver.Escape(column), row[column]))
+				}
+			}
+			if len(fields) > 0 {
+				if err = driver.Update(table, fields, where); err != nil {
+					return inserted, updated, err
+				}
+			}
+			updated++
+			continue
+		}
+		fields := make([]db.SQLField, 0, len(columns))
+		for _, column := range columns {
+			fields = append(fields, db.NewSQLField(driver.Escape(column), row[column]))
+		}
+		if _, err = driver.Insert(table, fields); err != nil {
+			return inserted, updated, err
+		}
+		inserted++
+	}
+	return inserted, updated, nil
+}
+
+/*****************************************************************************************************************
+ * helper
+ */
+
+func isSeedTagged(seed db.Seed, tags []string) bool {
+	if len(seed.Tags()) == 0 {
+		return true
+	}
+	for _, tag := range seed.Tags() {
+		if helper.StringsIndexOf(tags, tag) >= 0 {
+			return true
+		}
+	}
+	return false
+}
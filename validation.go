@@ -0,0 +1,235 @@
+package grest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Validation rule
+ */
+type Rule interface {
+	Code() string
+	Message() string
+	Check(value interface{}) bool
+}
+
+func RuleCustom(code, message string, check func(value interface{}) bool) Rule {
+	return newRule(code, message, check)
+}
+
+// not null, not empty
+func RuleRequired() Rule {
+	return newRule("required", "value is required", func(value interface{}) bool {
+		if value == nil {
+			return false
+		}
+		if l, ok := getValueLength(value); ok {
+			return l > 0
+		}
+		return true
+	})
+}
+
+func RuleMin(min float64) Rule {
+	return newRule("min", fmt.Sprintf("value must be greater than or equal to %v", min), func(value interface{}) bool {
+		if value == nil {
+			return true
+		}
+		v, ok := getValueNumber(value)
+		return ok && v >= min
+	})
+}
+
+func RuleMax(max float64) Rule {
+	return newRule("max", fmt.Sprintf("value must be less than or equal to %v", max), func(value interface{}) bool {
+		if value == nil {
+			return true
+		}
+		v, ok := getValueNumber(value)
+		return ok && v <= max
+	})
+}
+
+// max < 0 is unlimited
+func RuleLength(min, max int) Rule {
+	message := fmt.Sprintf("length must be between %d and %d", min, max)
+	if max < 0 {
+		message = fmt.Sprintf("length must be at least %d", min)
+	}
+	return newRule("length", message, func(value interface{}) bool {
+		if value == nil {
+			return true
+		}
+		l, ok := getValueLength(value)
+		return ok && l >= min && (max < 0 || l <= max)
+	})
+}
+
+func RuleRegex(pattern string) Rule {
+	rx := regexp.MustCompile(pattern)
+	return newRule("regex", fmt.Sprintf("value must match %s", pattern), func(value interface{}) bool {
+		if value == nil {
+			return true
+		}
+		v, ok := value.(string)
+		return ok && rx.MatchString(v)
+	})
+}
+
+func RuleEnum(values ...interface{}) Rule {
+	items := make([]string, 0)
+	for _, v := range values {
+		items = append(items, fmt.Sprint(v))
+	}
+	return newRule("enum", fmt.Sprintf("value must be one of %s", strings.Join(items, ", ")), func(value interface{}) bool {
+		if value == nil {
+			return true
+		}
+		for _, v := range values {
+			if isValueEqual(value, v) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func RuleEmail() Rule {
+	rx := regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	return newRule("email", "value must be an email address", func(value interface{}) bool {
+		if value == nil {
+			return true
+		}
+		v, ok := value.(string)
+		return ok && rx.MatchString(v)
+	})
+}
+
+func newRule(code, message string, check func(value interface{}) bool) *rule {
+	result := rule{}
+	result.code = code
+	result.message = message
+	result.check = check
+	return &result
+}
+
+type rule struct {
+	code    string
+	message string
+	check   func(value interface{}) bool
+}
+
+func (this *rule) Code() string {
+	return this.code
+}
+
+func (this *rule) Message() string {
+	return this.message
+}
+
+func (this *rule) Check(value interface{}) bool {
+	if this.check == nil {
+		return true
+	}
+	return this.check(value)
+}
+
+/**
+ * Validation error (422)
+ */
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (this *ValidationError) Append(field, code, message string) {
+	this.Fields = append(this.Fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (this *ValidationError) Empty() bool {
+	return len(this.Fields) == 0
+}
+
+func (this *ValidationError) Status() int {
+	return http.StatusUnprocessableEntity
+}
+
+func (this *ValidationError) Text() string {
+	return http.StatusText(this.Status())
+}
+
+func (this *ValidationError) Error() string {
+	names := make([]string, 0)
+	for _, f := range this.Fields {
+		if len(names) == 0 || names[len(names)-1] != f.Field {
+			names = append(names, f.Field)
+		}
+	}
+	if len(names) == 1 {
+		return fmt.Sprintf("wrong field %s", names[0])
+	}
+	return fmt.Sprintf("wrong fields %s", strings.Join(names, ", "))
+}
+
+func (this *ValidationError) sort() {
+	sort.SliceStable(this.Fields, func(i, j int) bool { return this.Fields[i].Field < this.Fields[j].Field })
+}
+
+/***********************************************************************************************************************
+ * helper
+ */
+func getValueNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func getValueLength(value interface{}) (int, bool) {
+	if v, ok := value.(string); ok {
+		return len([]rune(v)), true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), true
+	}
+	return 0, false
+}
+
+func isValueEqual(a, b interface{}) bool {
+	if x, ok := getValueNumber(a); ok {
+		if _, isString := a.(string); !isString {
+			if y, ok := getValueNumber(b); ok {
+				return x == y
+			}
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}